@@ -3,6 +3,7 @@
 package main
 
 import (
+	"docker-image/model"
 	"docker-image/service"
 	"flag"
 	"fmt"
@@ -10,12 +11,46 @@ import (
 )
 
 var (
-	image    = flag.String("i", "", "layer id, image id, image:tag") // 层id, 镜像id, 镜像:tag
-	layer    = flag.Bool("layer", false, "docker-image layer")       // 镜像层
-	history  = flag.Bool("history", false, "docker-image history")   // 镜像 history记录
-	relation = flag.Bool("relation", false, "docker-image relation") // 镜像层关联的镜像
-	file     = flag.String("file", "", "docker-image file")          // 镜像文件路径
-	none     = flag.Bool("none", false, "docker-image none")         // none标记镜像相似镜像层名称
+	image      = flag.String("i", "", "layer id, image id, image:tag, container id") // 层id, 镜像id, 镜像:tag, 容器id
+	layer      = flag.Bool("layer", false, "docker-image layer")                     // 镜像层
+	history    = flag.Bool("history", false, "docker-image history")                 // 镜像 history记录
+	relation   = flag.Bool("relation", false, "docker-image relation")               // 镜像层关联的镜像及容器
+	file       = flag.String("file", "", "docker-image file")                        // 镜像文件路径
+	fileHash   = flag.String("hash", "", "docker-image file digest")                 // 镜像文件摘要
+	algo       = flag.String("algo", "sha256", "sha256 | blake3 | xxhash | md5")     // 文件摘要算法
+	none       = flag.Bool("none", false, "docker-image none")                       // none标记镜像相似镜像层名称
+	ls         = flag.String("ls", "", "docker-image ls")                            // 镜像合并视图目录
+	cat        = flag.String("cat", "", "docker-image cat")                          // 镜像合并视图文件内容
+	stat       = flag.String("stat", "", "docker-image stat")                        // 镜像合并视图文件信息
+	verify     = flag.Bool("verify", false, "docker-image verify")                   // 校验镜像层完整性
+	orphans    = flag.Bool("orphans", false, "docker-image orphans")                 // 未被引用的overlay2目录
+	check      = flag.Bool("check", false, "docker-image check")                     // 检查镜像层lower链
+	containers = flag.Bool("containers", false, "docker-image containers")           // 容器读写层
+	tree       = flag.Bool("tree", false, "docker-image tree")                       // 镜像父子关系树
+	graph      = flag.String("graph", "", "docker-image graph, dot | mermaid")       // 镜像层关系图格式
+	filter     = flag.String("filter", "", "image name pattern")                     // 镜像名称过滤条件
+	share      = flag.Bool("share", false, "docker-image share")                     // 镜像共享层统计
+	planRm     = flag.Bool("plan-rm", false, "docker-image plan-rm image...")        // 删除镜像可释放空间
+	pruneNone  = flag.Bool("prune-none", false, "docker-image prune-none")           // 清理none标记镜像
+	del        = flag.Bool("delete", false, "delete images, default dry run")        // 执行删除
+	journal    = flag.Bool("journal", false, "record image:tag history")             // 记录镜像TAG变化
+	tagHistory = flag.String("tag-history", "", "docker-image tag-history")          // 镜像TAG变化记录
+	name       = flag.String("name", "", "file name pattern")                        // 文件名通配符
+	path       = flag.String("path", "", "file path pattern")                        // 镜像内路径通配符
+	grep       = flag.String("grep", "", "file content regexp")                      // 文件内容正则表达式
+	maxSize    = flag.Int64("max-size", 10<<20, "grep max file size, 0 no limit")    // 搜索文件大小上限
+	index      = flag.Bool("index", false, "use and update file digest index")       // 文件摘要索引
+	workers    = flag.Int("workers", 10, "file scan workers")                        // 文件扫描并发数
+	ioLimit    = flag.Int64("io-limit", 0, "file read limit MB/s, 0 no limit")       // 文件读取速率限制
+	lowPrio    = flag.Bool("low-priority", false, "ionice idle, nice 19")            // 低优先级运行
+	progress   = flag.Bool("progress", false, "scan progress to stderr")             // 扫描进度
+	raw        = flag.Bool("raw", false, "show overlay2 storage path")               // 输出实际存储路径
+	dataRoot   = flag.String("data-root", "", "docker data-root directory")          // docker数据目录
+	findBinary = flag.String("find-binary", "", "build-id: | module: | revision:")   // ELF build-id 或 Go 模块信息
+	packages   = flag.Bool("packages", false, "docker-image packages")               // 镜像安装的包
+	sbom       = flag.String("sbom", "", "spdx | cyclonedx")                         // SBOM格式
+	vuln       = flag.String("vuln", "", "OSV advisory db, zip | dir | json")        // 本地OSV漏洞库
+	secrets    = flag.Bool("secrets", false, "docker-image secrets")                 // 镜像层敏感信息
 )
 
 func main() {
@@ -26,17 +61,167 @@ func main() {
 			"   docker-image -layer -i xxxxxxxx \n" +
 			"   docker-image -history -i xxxxxxxx \n" +
 			"   docker-image -relation -i xxxxxxxx \n" +
-			"   docker-image -file /root/file.txt \n" +
-			"   docker-image -none -i xxxxxxxx \n"
+			"   docker-image -file /root/file.txt [-raw] \n" +
+			"   docker-image -hash sha256:xxxxxxxx \n" +
+			"   docker-image -index [-file /root/file.txt] \n" +
+			"   docker-image -none [-i xxxxxxxx] \n" +
+			"   docker-image -ls /etc -i xxxxxxxx \n" +
+			"   docker-image -cat /etc/passwd -i xxxxxxxx \n" +
+			"   docker-image -stat /etc/passwd -i xxxxxxxx \n" +
+			"   docker-image -verify [-i xxxxxxxx] \n" +
+			"   docker-image -orphans \n" +
+			"   docker-image -check [-i xxxxxxxx] \n" +
+			"   docker-image -containers [-i container] \n" +
+			"   docker-image -tree [-i xxxxxxxx] \n" +
+			"   docker-image -graph dot [-filter 'kubeovn/*'] \n" +
+			"   docker-image -share [-filter 'kubeovn/*'] \n" +
+			"   docker-image -plan-rm xxxxxxxx image:tag \n" +
+			"   docker-image -prune-none [-delete] \n" +
+			"   docker-image -journal \n" +
+			"   docker-image -tag-history image:tag \n" +
+			"   docker-image -name '*.so' \n" +
+			"   docker-image -path '/usr/lib/**/*.jar' \n" +
+			"   docker-image -grep 'password=' [-max-size 1048576] \n" +
+			"   docker-image -find-binary module:github.com/kubeovn/kube-ovn@v1.11.13 \n" +
+			"   docker-image -packages -i xxxxxxxx \n" +
+			"   docker-image -sbom spdx -i xxxxxxxx > sbom.json \n" +
+			"   docker-image -vuln /root/osv/all.zip [-i xxxxxxxx] \n" +
+			"   docker-image -secrets [-max-size 1048576] \n" +
+			"   docker-image -file /root/file.txt -workers 4 -io-limit 50 -low-priority -progress \n"
 		fmt.Fprintf(os.Stderr, examples)
 	}
 	flag.Parse()
-	s := service.ImageRelation{}
-	if *file != "" {
+	if *dataRoot == "" {
+		if root, err := model.DockerInstance.DockerRootDir(); err == nil {
+			*dataRoot = root
+		}
+	}
+	service.SetDataRoot(*dataRoot)
+	s := service.ImageRelation{
+		Workers:     *workers,
+		IOLimit:     *ioLimit << 20,
+		LowPriority: *lowPrio,
+		Progress:    *progress,
+	}
+	if *journal {
+		s.RecordTagHistory()
+		// 只有 -journal 及全局选项时记录后退出, 否则继续执行其他命令
+		command := false
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "journal", "data-root", "workers", "io-limit", "low-priority", "progress":
+			default:
+				command = true
+			}
+		})
+		if !command {
+			os.Exit(0)
+		}
+	}
+	if *tagHistory != "" {
+		s.ImageId = *tagHistory
+		s.TagHistory()
+		os.Exit(0)
+	}
+	if *file != "" || *fileHash != "" {
 		s.ImageFile = *file
+		s.FileHash = *fileHash
+		s.HashAlgorithm = *algo
+		s.UseIndex = *index
+		s.StoragePath = *raw
 		s.ContainsBinaryfile()
 		os.Exit(0)
 	}
+	if *index {
+		s.HashAlgorithm = *algo
+		s.UpdateFileIndex()
+		os.Exit(0)
+	}
+	if *name != "" || *path != "" {
+		s.NamePattern = *name
+		s.PathPattern = *path
+		s.FindImageFile()
+		os.Exit(0)
+	}
+	if *grep != "" {
+		s.Pattern = *grep
+		s.MaxFileSize = *maxSize
+		s.GrepImageFile()
+		os.Exit(0)
+	}
+	if *findBinary != "" {
+		s.Pattern = *findBinary
+		s.FindBinary()
+		os.Exit(0)
+	}
+	if *vuln != "" {
+		s.ImageId = *image
+		s.AdvisoryDB = *vuln
+		s.ImageVulnerability()
+		os.Exit(0)
+	}
+	if *secrets {
+		s.MaxFileSize = *maxSize
+		s.SecretScan()
+		os.Exit(0)
+	}
+	if *orphans {
+		s.OrphanLayer()
+		os.Exit(0)
+	}
+	if *pruneNone {
+		s.Delete = *del
+		s.PruneNoneImage()
+		os.Exit(0)
+	}
+	if *planRm {
+		if flag.NArg() == 0 {
+			fmt.Fprintf(os.Stderr, "error: docker-image -plan-rm image is null")
+			os.Exit(0)
+		}
+		s.ImageRefs = flag.Args()
+		s.PlanRemove()
+		os.Exit(0)
+	}
+	if *share {
+		s.Pattern = *filter
+		s.LayerShareReport()
+		os.Exit(0)
+	}
+	if *graph != "" {
+		s.Format = *graph
+		s.Pattern = *filter
+		s.ImageGraph()
+		os.Exit(0)
+	}
+	if *tree {
+		s.ImageId = *image
+		s.ImageTree()
+		os.Exit(0)
+	}
+	if *containers {
+		if *image == "" {
+			s.ContainerLayer()
+			os.Exit(0)
+		}
+		s.ImageId = *image
+		s.ContainerChanges()
+		os.Exit(0)
+	}
+	if *check {
+		s.ImageId = *image
+		s.CheckLowerChain()
+		os.Exit(0)
+	}
+	if *verify {
+		s.ImageId = *image
+		s.VerifyImageLayer()
+		os.Exit(0)
+	}
+	if *none && *image == "" {
+		s.ContainsNoneLayerImages()
+		os.Exit(0)
+	}
 	if *image == "" {
 		fmt.Fprintf(os.Stderr, "error: docker-image -i parameter is null")
 		os.Exit(0)
@@ -51,5 +236,19 @@ func main() {
 		s.ContainsImageLayerID()
 	case *none:
 		s.ContainsNoneLayerImage()
+	case *ls != "":
+		s.ImagePath = *ls
+		s.MergedList()
+	case *cat != "":
+		s.ImagePath = *cat
+		s.MergedCat()
+	case *stat != "":
+		s.ImagePath = *stat
+		s.MergedStat()
+	case *packages:
+		s.ImagePackages()
+	case *sbom != "":
+		s.Format = *sbom
+		s.ImageSBOM()
 	}
 }
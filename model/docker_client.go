@@ -2,9 +2,15 @@ package model
 
 import (
 	"context"
+	"io"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
 )
@@ -46,6 +52,15 @@ func (d *DockerClient) ImageInspect(imageId string) (*types.ImageInspect, error)
 	return &resp, nil
 }
 
+// DockerRootDir daemon 的数据目录
+func (d *DockerClient) DockerRootDir() (string, error) {
+	info, err := d.Client.Info(context.TODO())
+	if err != nil {
+		return "", err
+	}
+	return info.DockerRootDir, nil
+}
+
 func (d *DockerClient) ImageList() ([]image.Summary, error) {
 	resp, err := d.Client.ImageList(context.TODO(), types.ImageListOptions{All: true})
 	if err != nil {
@@ -61,3 +76,40 @@ func (d *DockerClient) ImageHistory(imageId string) ([]image.HistoryResponseItem
 	}
 	return resp, nil
 }
+
+func (d *DockerClient) ContainerList() ([]types.Container, error) {
+	resp, err := d.Client.ContainerList(context.TODO(), container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (d *DockerClient) ImageRemove(imageId string) ([]image.DeleteResponse, error) {
+	resp, err := d.Client.ImageRemove(context.TODO(), imageId, image.RemoveOptions{PruneChildren: false})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ImageEvents 获取 since 至今的镜像事件, daemon 只保留最近的部分事件
+func (d *DockerClient) ImageEvents(since int64) ([]events.Message, error) {
+	messages, errs := d.Client.Events(context.TODO(), types.EventsOptions{
+		Since:   strconv.FormatInt(since, 10),
+		Until:   strconv.FormatInt(time.Now().Unix(), 10),
+		Filters: filters.NewArgs(filters.Arg("type", string(events.ImageEventType))),
+	})
+	resp := make([]events.Message, 0)
+	for {
+		select {
+		case message := <-messages:
+			resp = append(resp, message)
+		case err := <-errs:
+			if err == io.EOF {
+				return resp, nil
+			}
+			return nil, err
+		}
+	}
+}
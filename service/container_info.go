@@ -0,0 +1,60 @@
+package service
+
+import (
+	"docker-image/model"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ContainerInfo struct {
+	ContainerID string `json:"container_id"` // 容器ID
+	Name        string `json:"name"`         // 容器名称
+	Image       string `json:"image"`        // 创建容器时使用的镜像
+	ImageID     string `json:"image_id"`     // 镜像ID
+	State       string `json:"state"`        // 容器状态
+	MountID     string `json:"mount_id"`     // 读写层 overlay2 目录
+	InitID      string `json:"init_id"`      // init层 overlay2 目录
+}
+
+// 获取所有容器信息, 读写层来自 layerdb/mounts/<容器ID>/mount-id
+func getAllContainersInfo() ([]*ContainerInfo, error) {
+	containerList, err := model.DockerInstance.ContainerList()
+	if err != nil {
+		return nil, err
+	}
+	containersInfo := make([]*ContainerInfo, 0, len(containerList))
+	for _, container := range containerList {
+		name := ""
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		mountID, _ := os.ReadFile(filepath.Join(layerdbPath, "mounts", container.ID, "mount-id"))
+		initID, _ := os.ReadFile(filepath.Join(layerdbPath, "mounts", container.ID, "init-id"))
+		containersInfo = append(containersInfo, &ContainerInfo{
+			ContainerID: container.ID,
+			Name:        name,
+			Image:       container.Image,
+			ImageID:     container.ImageID,
+			State:       container.State,
+			MountID:     strings.TrimSpace(string(mountID)),
+			InitID:      strings.TrimSpace(string(initID)),
+		})
+	}
+	return containersInfo, nil
+}
+
+// 根据容器ID或名称获取容器信息
+func containerInfoFromId(containerId string) (*ContainerInfo, error) {
+	containersInfo, err := getAllContainersInfo()
+	if err != nil {
+		return nil, err
+	}
+	for _, container := range containersInfo {
+		if container.Name == containerId || strings.HasPrefix(container.ContainerID, containerId) {
+			return container, nil
+		}
+	}
+	return nil, errors.New("Not found docker container")
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ContainerLayerData struct {
+	ContainerID string `json:"container_id"` // 容器ID
+	Name        string `json:"name"`         // 容器名称
+	Image       string `json:"image"`        // 镜像
+	ImageID     string `json:"image_id"`     // 镜像ID
+	State       string `json:"state"`        // 容器状态
+	Size        string `json:"size"`         // 读写层大小
+	StoragePath string `json:"storage_path"` // 读写层存储路径
+}
+
+type ContainerChangeData struct {
+	Kind string `json:"kind"` // A 新增 | C 修改 | D 删除
+	Path string `json:"path"` // 容器内路径
+	Size string `json:"size"` // 文件大小
+}
+
+func (i ImageRelation) ContainerLayer() {
+	containersInfo, err := getAllContainersInfo()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	containerLayersData := make([]*ContainerLayerData, 0, len(containersInfo))
+	nameSize := len("NAMES")
+	imageSize := len("IMAGE")
+	for _, container := range containersInfo {
+		storagePath := ""
+		size := ""
+		if container.MountID != "" {
+			storagePath = filepath.Join(overlay2Path, container.MountID)
+			s, _ := util.DirSize(filepath.Join(storagePath, "diff"))
+			size = util.ImageSize(s)
+		}
+		containerLayersData = append(containerLayersData, &ContainerLayerData{
+			ContainerID: container.ContainerID[:12],
+			Name:        container.Name,
+			Image:       container.Image,
+			ImageID:     strings.ReplaceAll(container.ImageID, "sha256:", "")[:12],
+			State:       container.State,
+			Size:        size,
+			StoragePath: storagePath,
+		})
+		if len(container.Name) > nameSize {
+			nameSize = len(container.Name)
+		}
+		if len(container.Image) > imageSize {
+			imageSize = len(container.Image)
+		}
+	}
+	outputContainerLayer(containerLayersData, strconv.Itoa(nameSize), strconv.Itoa(imageSize))
+}
+
+// ContainerChanges 对比容器读写层与镜像合并视图, 找出容器修改的文件
+func (i ImageRelation) ContainerChanges() {
+	container, err := containerInfoFromId(i.ImageId)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if container.MountID == "" {
+		log.Fatalln("Not found container mount-id")
+	}
+	image := mustImageInfo(container.ImageID)
+	upperPath := filepath.Join(overlay2Path, container.MountID, "diff")
+	// init 层(hosts、resolv.conf 等)由 docker 生成, 不属于镜像, 也不输出其内容
+	initPath := ""
+	if container.InitID != "" {
+		initPath = filepath.Join(overlay2Path, container.InitID, "diff")
+	}
+	containerChangesData := make([]*ContainerChangeData, 0)
+	err = filepath.Walk(upperPath, func(subPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if subPath == upperPath || info.Name() == util.WhiteoutOpaqueDir {
+			return nil
+		}
+		path := "/" + strings.TrimPrefix(subPath, upperPath+"/")
+		if util.IsWhiteout(info) || strings.HasPrefix(info.Name(), util.WhiteoutPrefix) {
+			containerChangesData = append(containerChangesData, &ContainerChangeData{
+				Kind: "D",
+				Path: filepath.Join(filepath.Dir(path), strings.TrimPrefix(info.Name(), util.WhiteoutPrefix)),
+			})
+			return nil
+		}
+		if info.IsDir() && util.IsOpaqueDir(subPath) {
+			// opaque 目录屏蔽下层同名目录, 下层中读写层没有的内容均已删除
+			containerChangesData = append(containerChangesData, opaqueDeletions(image, upperPath, path)...)
+		}
+		var lowerInfo os.FileInfo
+		if entries := mergedLayers(image, path); len(entries) > 0 {
+			lowerInfo = entries[0].Info
+		} else if initPath != "" {
+			// 仅存在于 init 层的路径视为修改
+			lowerInfo, _ = os.Lstat(filepath.Join(initPath, path))
+		}
+		if lowerInfo == nil {
+			containerChangesData = append(containerChangesData, &ContainerChangeData{
+				Kind: "A",
+				Path: path,
+				Size: strconv.FormatInt(info.Size(), 10),
+			})
+			return nil
+		}
+		if info.IsDir() && lowerInfo.IsDir() {
+			return nil
+		}
+		containerChangesData = append(containerChangesData, &ContainerChangeData{
+			Kind: "C",
+			Path: path,
+			Size: strconv.FormatInt(info.Size(), 10),
+		})
+		return nil
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	outputContainerChange(containerChangesData)
+}
+
+// opaqueDeletions 列出 opaque 目录下镜像中存在而读写层中不存在的路径
+func opaqueDeletions(image *ImageInfo, upperPath, path string) []*ContainerChangeData {
+	entries := mergedLayers(image, path)
+	if len(entries) == 0 || !entries[0].Info.IsDir() {
+		return nil
+	}
+	files := mergedDirEntries(entries)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	deletions := make([]*ContainerChangeData, 0)
+	for _, name := range names {
+		subPath := filepath.Join(path, name)
+		info, err := os.Lstat(filepath.Join(upperPath, subPath))
+		if err != nil {
+			deletions = append(deletions, &ContainerChangeData{
+				Kind: "D",
+				Path: subPath,
+			})
+			continue
+		}
+		// 读写层中的同名目录同样屏蔽下层内容, 自身为 opaque 的目录由遍历处理
+		if info.IsDir() && files[name].Info.IsDir() && !util.IsOpaqueDir(filepath.Join(upperPath, subPath)) {
+			deletions = append(deletions, opaqueDeletions(image, upperPath, subPath)...)
+		}
+	}
+	return deletions
+}
+
+func outputContainerLayer(data []*ContainerLayerData, nameSize, imageSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-12s %-1111s %-9999s %-12s %-10s %-10s %s\n", "1111", nameSize), "9999", imageSize)
+	fmt.Fprintf(os.Stdout, format, "CONTAINER ID", "NAMES", "IMAGE", "IMAGE ID", "STATE", "SIZE", "STORAGE")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ContainerID, v.Name, v.Image, v.ImageID, v.State, v.Size, v.StoragePath)
+	}
+}
+
+func outputContainerChange(data []*ContainerChangeData) {
+	format := "%-4s %-12s %s\n"
+	fmt.Fprintf(os.Stdout, format, "KIND", "SIZE", "PATH")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.Kind, v.Size, v.Path)
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type FindBinaryData struct {
+	*FindFileData
+	Match string `json:"match"` // 匹配到的标识
+}
+
+// binaryQuery 查询条件 build-id:<hex> | module:<path>[@version] | revision:<vcs revision>
+type binaryQuery struct {
+	kind  string
+	value string
+}
+
+func parseBinaryQuery(query string) (*binaryQuery, error) {
+	split := strings.SplitN(query, ":", 2)
+	if len(split) != 2 || split[1] == "" {
+		return nil, fmt.Errorf("invalid query %q, use build-id:<hex> | module:<path>[@version] | revision:<rev>", query)
+	}
+	switch split[0] {
+	case "build-id", "revision":
+		return &binaryQuery{kind: split[0], value: strings.ToLower(split[1])}, nil
+	case "module":
+		return &binaryQuery{kind: split[0], value: split[1]}, nil
+	}
+	return nil, fmt.Errorf("unsupported query type %q", split[0])
+}
+
+// matchModule 模块路径相同, 指定版本时版本也需要相同
+func (q *binaryQuery) matchModule(path, version string) bool {
+	want := strings.SplitN(q.value, "@", 2)
+	if want[0] != path {
+		return false
+	}
+	return len(want) == 1 || want[1] == version
+}
+
+// match 返回匹配到的标识, 不匹配时返回空字符串
+func (q *binaryQuery) match(path string) string {
+	if q.kind == "build-id" {
+		id, err := util.ELFBuildID(path)
+		if err != nil || id == "" || !strings.HasPrefix(id, q.value) {
+			return ""
+		}
+		return "build-id " + id
+	}
+	info := util.GoBuildInfo(path)
+	if info == nil {
+		return ""
+	}
+	switch q.kind {
+	case "module":
+		if q.matchModule(info.Main.Path, info.Main.Version) {
+			return info.Main.Path + " " + info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Replace != nil && q.matchModule(dep.Replace.Path, dep.Replace.Version) {
+				return dep.Replace.Path + " " + dep.Replace.Version + " (replace " + dep.Path + ")"
+			}
+			if q.matchModule(dep.Path, dep.Version) {
+				return dep.Path + " " + dep.Version + " (dep of " + info.Main.Path + ")"
+			}
+		}
+	case "revision":
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && strings.HasPrefix(setting.Value, q.value) {
+				return info.Main.Path + " vcs.revision " + setting.Value
+			}
+		}
+	}
+	return ""
+}
+
+// FindBinary 按 ELF build-id 或 Go 模块信息查找所有镜像层中的可执行文件, 重新编译但内容相同的程序也能找到
+func (i ImageRelation) FindBinary() {
+	query, err := parseBinaryQuery(i.Pattern)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var mu sync.Mutex
+	fileMatches := make(map[string]string)
+	sc := i.newScanner()
+	paths := sc.walkOverlay2(func(subPath string, info os.FileInfo) bool {
+		if !info.Mode().IsRegular() || info.Size() < 64 || !util.IsELF(subPath) {
+			return false
+		}
+		sc.progress.AddFile(info.Size())
+		match := query.match(subPath)
+		if match == "" {
+			return false
+		}
+		mu.Lock()
+		fileMatches[subPath] = match
+		mu.Unlock()
+		return true
+	})
+	sc.close()
+	findBinaryDatas := make([]*FindBinaryData, 0)
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	pathSize := len("PATH")
+	matchSize := len("MATCH")
+	for _, path := range paths {
+		for _, data := range imageFileData([]string{path}) {
+			findBinaryDatas = append(findBinaryDatas, &FindBinaryData{FindFileData: data, Match: fileMatches[path]})
+			if len(data.ImageName) > repoSize {
+				repoSize = len(data.ImageName)
+			}
+			if len(data.ImageTag) > tagSize {
+				tagSize = len(data.ImageTag)
+			}
+			if len(data.Path) > pathSize {
+				pathSize = len(data.Path)
+			}
+			if len(fileMatches[path]) > matchSize {
+				matchSize = len(fileMatches[path])
+			}
+		}
+	}
+	sort.SliceStable(findBinaryDatas, func(a, b int) bool {
+		x, y := findBinaryDatas[a], findBinaryDatas[b]
+		if x.ImageName+":"+x.ImageTag != y.ImageName+":"+y.ImageTag {
+			return x.ImageName+":"+x.ImageTag < y.ImageName+":"+y.ImageTag
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.Layer < y.Layer
+	})
+	outputFindBinary(findBinaryDatas, strconv.Itoa(repoSize), strconv.Itoa(tagSize), strconv.Itoa(pathSize), strconv.Itoa(matchSize))
+}
+
+func outputFindBinary(data []*FindBinaryData, repoSize, tagSize, pathSize, matchSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-5s %-8888s %-7777s %s\n", "1111", repoSize), "9999", tagSize)
+	format = strings.ReplaceAll(strings.ReplaceAll(format, "8888", pathSize), "7777", matchSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "LAYER", "PATH", "MATCH", "CREATED BY")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, strconv.Itoa(v.Layer), v.Path, v.Match, v.CreatedBy)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type FindFileData struct {
+	ImageNameData
+	ImageID   string `json:"image_id"`   // 镜像ID
+	DiffID    string `json:"diff_id"`    // 所在镜像层 DiffID
+	Layer     int    `json:"layer"`      // 镜像层序号
+	CreatedBy string `json:"created_by"` // 创建该层的指令
+	Path      string `json:"path"`       // 镜像内路径
+}
+
+// imageFileData 将 overlay2 中的文件对应到镜像、镜像层及创建该层的指令
+func imageFileData(paths []string) []*FindFileData {
+	findFilesData := make([]*FindFileData, 0)
+	createdBy := make(map[string][]string)
+	for _, path := range paths {
+		cacheID, imagePath := splitOverlay2Path(path)
+		for _, image := range GetAllImagesInstance().ImageInfoFromLayerId(cacheID) {
+			idx := imageLayerIndex(image, cacheID)
+			if idx < 0 {
+				continue
+			}
+			if _, ok := createdBy[image.ImageID]; !ok {
+				createdBy[image.ImageID] = imageLayerCreatedBy(image)
+			}
+			findFilesData = append(findFilesData, &FindFileData{
+				ImageNameData: image.ImageNameData,
+				ImageID:       strings.ReplaceAll(image.ImageID, "sha256:", "")[:12],
+				DiffID:        image.ImageLayerIDS[idx].DiffID[:12],
+				Layer:         idx,
+				CreatedBy:     createdBy[image.ImageID][idx],
+				Path:          imagePath,
+			})
+		}
+	}
+	sort.SliceStable(findFilesData, func(a, b int) bool {
+		x, y := findFilesData[a], findFilesData[b]
+		if x.ImageName+":"+x.ImageTag != y.ImageName+":"+y.ImageTag {
+			return x.ImageName+":"+x.ImageTag < y.ImageName+":"+y.ImageTag
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.Layer < y.Layer
+	})
+	return findFilesData
+}
+
+// FindImageFile 按文件名通配符或路径通配符查找所有镜像层中的文件
+func (i ImageRelation) FindImageFile() {
+	var pathRegexp *regexp.Regexp
+	if i.PathPattern != "" {
+		var err error
+		pathRegexp, err = util.GlobToRegexp(i.PathPattern)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if i.NamePattern != "" {
+		if _, err := filepath.Match(i.NamePattern, ""); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	sc := i.newScanner()
+	paths := sc.walkOverlay2(func(subPath string, info os.FileInfo) bool {
+		if util.IsWhiteout(info) || strings.HasPrefix(info.Name(), util.WhiteoutPrefix) {
+			return false
+		}
+		if i.NamePattern != "" {
+			if ok, _ := filepath.Match(i.NamePattern, info.Name()); !ok {
+				return false
+			}
+		}
+		if pathRegexp != nil {
+			_, imagePath := splitOverlay2Path(subPath)
+			if !pathRegexp.MatchString(imagePath) {
+				return false
+			}
+		}
+		return true
+	})
+	sc.close()
+	findFilesData := imageFileData(paths)
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	pathSize := len("PATH")
+	for _, v := range findFilesData {
+		if len(v.ImageName) > repoSize {
+			repoSize = len(v.ImageName)
+		}
+		if len(v.ImageTag) > tagSize {
+			tagSize = len(v.ImageTag)
+		}
+		if len(v.Path) > pathSize {
+			pathSize = len(v.Path)
+		}
+	}
+	outputFindFile(findFilesData, strconv.Itoa(repoSize), strconv.Itoa(tagSize), strconv.Itoa(pathSize))
+}
+
+func outputFindFile(data []*FindFileData, repoSize, tagSize, pathSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-12s %-5s %-8888s %s\n", "1111", repoSize), "9999", tagSize)
+	format = strings.ReplaceAll(format, "8888", pathSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "DIFF ID", "LAYER", "PATH", "CREATED BY")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, v.DiffID, strconv.Itoa(v.Layer), v.Path, v.CreatedBy)
+	}
+}
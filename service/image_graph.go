@@ -0,0 +1,132 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type GraphNode struct {
+	ID    string `json:"id"`    // 节点ID
+	Label string `json:"label"` // 节点显示名称
+	Image bool   `json:"image"` // 是否为镜像节点
+}
+
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// 镜像:TAG 或镜像ID 是否匹配过滤条件, 支持通配符
+func matchImagePattern(group *ImageGroup, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	if strings.HasPrefix(strings.ReplaceAll(group.ImageID, "sha256:", ""), pattern) {
+		return true
+	}
+	for _, name := range group.Names {
+		if ok, _ := path.Match(pattern, name); ok || strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageGraph 镜像层按 ChainID 连接成图, 镜像节点连接到最上层
+func imageGraph(pattern string) ([]*GraphNode, []*GraphEdge) {
+	nodes := make([]*GraphNode, 0)
+	edges := make([]*GraphEdge, 0)
+	seenNodes := make(map[string]bool)
+	seenEdges := make(map[string]bool)
+	addEdge := func(from, to string) {
+		if seenEdges[from+" "+to] {
+			return
+		}
+		seenEdges[from+" "+to] = true
+		edges = append(edges, &GraphEdge{From: from, To: to})
+	}
+	groups := GetAllImagesInstance().ImageGroups()
+	sort.Slice(groups, func(a, b int) bool {
+		return groups[a].Names[0] < groups[b].Names[0]
+	})
+	for _, group := range groups {
+		if !matchImagePattern(group, pattern) {
+			continue
+		}
+		prev := ""
+		for _, layer := range group.ImageLayerIDS {
+			id := "l_" + layer.ChainID[:12]
+			if !seenNodes[id] {
+				seenNodes[id] = true
+				nodes = append(nodes, &GraphNode{
+					ID:    id,
+					Label: layer.DiffID[:12] + "\n" + util.ImageSize(layerSize(layer)),
+				})
+			}
+			if prev != "" {
+				addEdge(prev, id)
+			}
+			prev = id
+		}
+		id := "i_" + strings.ReplaceAll(group.ImageID, "sha256:", "")[:12]
+		nodes = append(nodes, &GraphNode{
+			ID:    id,
+			Label: strings.Join(group.Names, "\n"),
+			Image: true,
+		})
+		if prev != "" {
+			addEdge(prev, id)
+		}
+	}
+	return nodes, edges
+}
+
+func (i ImageRelation) ImageGraph() {
+	nodes, edges := imageGraph(i.Pattern)
+	switch i.Format {
+	case "dot":
+		outputGraphDot(nodes, edges)
+	case "mermaid":
+		outputGraphMermaid(nodes, edges)
+	default:
+		log.Fatalln("unsupported graph format: " + i.Format)
+	}
+}
+
+func outputGraphDot(nodes []*GraphNode, edges []*GraphEdge) {
+	fmt.Fprintln(os.Stdout, "digraph layers {")
+	fmt.Fprintln(os.Stdout, "  rankdir=BT;")
+	fmt.Fprintln(os.Stdout, "  node [shape=box];")
+	for _, n := range nodes {
+		label := strings.ReplaceAll(strings.ReplaceAll(n.Label, `"`, `\"`), "\n", `\n`)
+		if n.Image {
+			fmt.Fprintf(os.Stdout, "  %s [label=\"%s\", shape=ellipse];\n", n.ID, label)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "  %s [label=\"%s\"];\n", n.ID, label)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(os.Stdout, "  %s -> %s;\n", e.From, e.To)
+	}
+	fmt.Fprintln(os.Stdout, "}")
+}
+
+func outputGraphMermaid(nodes []*GraphNode, edges []*GraphEdge) {
+	fmt.Fprintln(os.Stdout, "graph BT")
+	for _, n := range nodes {
+		label := strings.ReplaceAll(strings.ReplaceAll(n.Label, `"`, "#quot;"), "\n", "<br/>")
+		if n.Image {
+			fmt.Fprintf(os.Stdout, "  %s([\"%s\"])\n", n.ID, label)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "  %s[\"%s\"]\n", n.ID, label)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(os.Stdout, "  %s --> %s\n", e.From, e.To)
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 匹配行输出的最大长度
+const grepTextSize = 120
+
+type GrepFileData struct {
+	*FindFileData
+	Line int    `json:"line"` // 行号
+	Text string `json:"text"` // 匹配行内容
+}
+
+// GrepImageFile 按正则表达式搜索所有镜像层中文本文件的内容
+func (i ImageRelation) GrepImageFile() {
+	re, err := regexp.Compile(i.Pattern)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var mu sync.Mutex
+	fileMatches := make(map[string][]util.GrepMatch)
+	sc := i.newScanner()
+	paths := sc.walkOverlay2(func(subPath string, info os.FileInfo) bool {
+		if !info.Mode().IsRegular() || info.Size() == 0 || (i.MaxFileSize > 0 && info.Size() > i.MaxFileSize) {
+			return false
+		}
+		matches, err := util.GrepFile(subPath, re, sc.limiter)
+		sc.progress.AddFile(info.Size())
+		// 读取出错之前的匹配结果仍然保留
+		if err != nil {
+			log.Println(subPath, err)
+		}
+		if len(matches) == 0 {
+			return false
+		}
+		mu.Lock()
+		fileMatches[subPath] = matches
+		mu.Unlock()
+		return true
+	})
+	sc.close()
+	grepFilesData := make([]*GrepFileData, 0)
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	pathSize := len("PATH")
+	for _, path := range paths {
+		for _, data := range imageFileData([]string{path}) {
+			for _, match := range fileMatches[path] {
+				text := truncateText(strings.TrimSpace(match.Text), grepTextSize)
+				grepFilesData = append(grepFilesData, &GrepFileData{FindFileData: data, Line: match.Line, Text: text})
+			}
+			if len(data.ImageName) > repoSize {
+				repoSize = len(data.ImageName)
+			}
+			if len(data.ImageTag) > tagSize {
+				tagSize = len(data.ImageTag)
+			}
+			if len(data.Path) > pathSize {
+				pathSize = len(data.Path)
+			}
+		}
+	}
+	sort.SliceStable(grepFilesData, func(a, b int) bool {
+		x, y := grepFilesData[a], grepFilesData[b]
+		if x.ImageName+":"+x.ImageTag != y.ImageName+":"+y.ImageTag {
+			return x.ImageName+":"+x.ImageTag < y.ImageName+":"+y.ImageTag
+		}
+		if x.Layer != y.Layer {
+			return x.Layer < y.Layer
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.Line < y.Line
+	})
+	outputGrepFile(grepFilesData, strconv.Itoa(repoSize), strconv.Itoa(tagSize), strconv.Itoa(pathSize))
+}
+
+// truncateText 超过 size 字节时截断并加上 ..., 截断位置退回到 UTF-8 字符边界
+func truncateText(text string, size int) string {
+	if len(text) <= size {
+		return text
+	}
+	for size > 0 && !utf8.RuneStart(text[size]) {
+		size--
+	}
+	return text[:size] + "..."
+}
+
+func outputGrepFile(data []*GrepFileData, repoSize, tagSize, pathSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-5s %-8888s %-6s %s\n", "1111", repoSize), "9999", tagSize)
+	format = strings.ReplaceAll(format, "8888", pathSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "LAYER", "PATH", "LINE", "TEXT")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, strconv.Itoa(v.Layer), v.Path, strconv.Itoa(v.Line), v.Text)
+	}
+}
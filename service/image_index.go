@@ -0,0 +1,214 @@
+package service
+
+import (
+	"compress/gzip"
+	"docker-image/util"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 文件摘要索引目录, 每种摘要算法一个索引文件, 由 SetDataRoot 设置为 <data-root>-image
+var fileIndexDir = "/var/lib/docker-image"
+
+type FileIndexEntry struct {
+	Hash string `json:"hash"` // 文件摘要
+	Path string `json:"path"` // 镜像层内路径
+	Size int64  `json:"size"` // 文件大小
+}
+
+type FileIndex struct {
+	Algorithm string                       `json:"algorithm"` // 摘要算法
+	Layers    map[string][]*FileIndexEntry `json:"layers"`    // cache-id -> 文件列表
+	hashes    map[string][]*fileIndexRef   // 文件摘要 -> 所在镜像层及文件
+}
+
+type fileIndexRef struct {
+	CacheID string
+	*FileIndexEntry
+}
+
+// buildHashes 按文件摘要建立查找表, 避免每次查找遍历所有文件
+func (index *FileIndex) buildHashes() {
+	index.hashes = make(map[string][]*fileIndexRef)
+	for cacheID, entries := range index.Layers {
+		for _, entry := range entries {
+			index.hashes[entry.Hash] = append(index.hashes[entry.Hash], &fileIndexRef{CacheID: cacheID, FileIndexEntry: entry})
+		}
+	}
+}
+
+func fileIndexPath(algorithm string) string {
+	return filepath.Join(fileIndexDir, "file-index-"+algorithm+".json.gz")
+}
+
+func readFileIndex(algorithm string) (*FileIndex, error) {
+	index := &FileIndex{Algorithm: algorithm, Layers: make(map[string][]*FileIndexEntry)}
+	f, err := os.Open(fileIndexPath(algorithm))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	if err := json.NewDecoder(gz).Decode(index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// writeFileIndex 先写临时文件再重命名, 避免中断时损坏索引
+func writeFileIndex(index *FileIndex) error {
+	if err := os.MkdirAll(fileIndexDir, 0755); err != nil {
+		return err
+	}
+	tmpPath := fileIndexPath(index.Algorithm) + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	if err := json.NewEncoder(gz).Encode(index); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, fileIndexPath(index.Algorithm))
+}
+
+// indexLayer 计算镜像层内所有普通文件的摘要
+// 任一文件读取失败时返回错误, 该镜像层不加入索引, 下次更新时重新计算
+func indexLayer(sc *overlay2Scanner, cacheID, algorithm string) ([]*FileIndexEntry, error) {
+	entries := make([]*FileIndexEntry, 0)
+	diffPath := filepath.Join(overlay2Path, cacheID, "diff")
+	err := filepath.Walk(diffPath, func(subPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		sum, err := util.FileHashLimit(subPath, algorithm, sc.limiter)
+		if err != nil {
+			return err
+		}
+		sc.progress.AddFile(info.Size())
+		entries = append(entries, &FileIndexEntry{
+			Hash: sum,
+			Path: "/" + strings.TrimPrefix(subPath, diffPath+"/"),
+			Size: info.Size(),
+		})
+		return nil
+	})
+	return entries, err
+}
+
+// updateFileIndex 只为新增的镜像层计算摘要, 删除已不存在的镜像层
+// 镜像层内容不会变化, 容器读写层不加入索引
+func updateFileIndex(sc *overlay2Scanner, algorithm string) (*FileIndex, error) {
+	index, err := readFileIndex(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	cacheIDs := make(map[string]bool)
+	layers, err := os.ReadDir(filepath.Join(layerdbPath, "sha256"))
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers {
+		b, err := os.ReadFile(filepath.Join(layerdbPath, "sha256", layer.Name(), "cache-id"))
+		if err != nil {
+			continue
+		}
+		cacheIDs[strings.TrimSpace(string(b))] = true
+	}
+	changed := false
+	for cacheID := range index.Layers {
+		if !cacheIDs[cacheID] {
+			delete(index.Layers, cacheID)
+			changed = true
+		}
+	}
+	cacheIDChan := make(chan string, len(cacheIDs))
+	for cacheID := range cacheIDs {
+		if _, ok := index.Layers[cacheID]; !ok {
+			cacheIDChan <- cacheID
+		}
+	}
+	close(cacheIDChan)
+	sc.progress.AddLayers(len(cacheIDChan))
+	var w sync.WaitGroup
+	var m sync.Mutex
+	w.Add(sc.workers)
+	for i := 0; i < sc.workers; i++ {
+		go func() {
+			defer w.Done()
+			for cacheID := range cacheIDChan {
+				entries, err := indexLayer(sc, cacheID, algorithm)
+				sc.progress.LayerDone()
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				m.Lock()
+				index.Layers[cacheID] = entries
+				changed = true
+				m.Unlock()
+			}
+		}()
+	}
+	w.Wait()
+	if changed {
+		if err := writeFileIndex(index); err != nil {
+			return nil, err
+		}
+	}
+	index.buildHashes()
+	return index, nil
+}
+
+// lookupFileIndex 在索引中查找摘要相同的文件, 返回 overlay2 路径
+func lookupFileIndex(index *FileIndex, digest string, fileSize int64) []string {
+	paths := make([]string, 0)
+	for _, ref := range index.hashes[digest] {
+		if fileSize >= 0 && ref.Size != fileSize {
+			continue
+		}
+		paths = append(paths, filepath.Join(overlay2Path, ref.CacheID, "diff", ref.Path))
+	}
+	return paths
+}
+
+// UpdateFileIndex 更新文件摘要索引
+func (i ImageRelation) UpdateFileIndex() {
+	if _, err := util.NewHash(i.HashAlgorithm); err != nil {
+		log.Fatalln(err)
+	}
+	sc := i.newScanner()
+	index, err := updateFileIndex(sc, i.HashAlgorithm)
+	sc.close()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	files := 0
+	for _, entries := range index.Layers {
+		files += len(entries)
+	}
+	fmt.Fprintf(os.Stdout, "%s: %d layers, %d files\n", fileIndexPath(i.HashAlgorithm), len(index.Layers), files)
+}
@@ -3,17 +3,44 @@ package service
 import (
 	"crypto/sha256"
 	"docker-image/model"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/docker/docker/api/types/image"
 )
 
 var getAllImagesData *GetImagesInfo
 
+// docker 默认数据目录
+const defaultDataRoot = "/var/lib/docker"
+
+var (
+	overlay2Path = "/var/lib/docker/overlay2/"                              // 镜像层实际存储目录
+	layerdbPath  = "/var/lib/docker/image/overlay2/layerdb/"                // 镜像层元数据目录
+	imagedbPath  = "/var/lib/docker/image/overlay2/imagedb/content/sha256/" // 镜像配置目录
+)
+
+// SetDataRoot 设置 docker 数据目录(daemon.json 中的 data-root), 需要在读取镜像信息前调用
+func SetDataRoot(dataRoot string) {
+	if dataRoot == "" {
+		dataRoot = defaultDataRoot
+	}
+	dataRoot = filepath.Clean(dataRoot)
+	overlay2Path = filepath.Join(dataRoot, "overlay2") + "/"
+	layerdbPath = filepath.Join(dataRoot, "image", "overlay2", "layerdb") + "/"
+	imagedbPath = filepath.Join(dataRoot, "image", "overlay2", "imagedb", "content", "sha256") + "/"
+	// 工具自身的数据(文件索引、TAG 变化记录)与 docker 数据目录一一对应
+	fileIndexDir = dataRoot + "-image"
+	tagJournalPath = filepath.Join(fileIndexDir, "tag-history.jsonl")
+}
+
 type ImagesInfoInterface interface {
 	// 从缓存中拿去image信息
 	Load() []*ImageInfo
@@ -35,6 +62,9 @@ type ImagesInfoInterface interface {
 
 	// 根据镜像id获取none标记镜像信息
 	ImageInfoFromNoneImageId(imageId string) *ImageInfo
+
+	// 按镜像ID合并镜像信息
+	ImageGroups() []*ImageGroup
 }
 
 type GetImagesInfo struct {
@@ -44,10 +74,19 @@ type GetImagesInfo struct {
 type ImageInfo struct {
 	ImageNameData
 	ImageID       string                      `json:"image_id"`        // 镜像ID
+	ParentID      string                      `json:"parent_id"`       // 父镜像ID, 本地构建时的中间镜像
+	Created       int64                       `json:"created"`         // 创建时间
+	RepoDigests   []string                    `json:"repo_digests"`    // 镜像仓库摘要
 	ImageLayerIDS []*ImageLayerID             `json:"image_layer_ids"` // 镜像内容寻址
 	ImagesHistory []image.HistoryResponseItem `json:"images_history"`  // 镜像存储位置信息
 }
 
+// 同一镜像ID的多个 镜像:TAG
+type ImageGroup struct {
+	*ImageInfo
+	Names []string `json:"names"` // 镜像:TAG
+}
+
 type ImageLayerID struct {
 	DiffID  string `json:"diff_id"`
 	ChainID string `json:"chain_id"`
@@ -62,10 +101,6 @@ func GetAllImagesInstance() *GetImagesInfo {
 	return getAllImagesData
 }
 
-func init() {
-	GetAllImagesInstance()
-}
-
 func (i *GetImagesInfo) Load() []*ImageInfo {
 	c := i.cache.Load()
 	if c == nil {
@@ -116,6 +151,9 @@ func (i *GetImagesInfo) getAllImagesInfo() ([]*ImageInfo, error) {
 				},
 				ImageLayerIDS: *imagelayerIds,
 				ImageID:       image.ID,
+				ParentID:      image.ParentID,
+				Created:       image.Created,
+				RepoDigests:   image.RepoDigests,
 				ImagesHistory: imagesHistory,
 			})
 			continue
@@ -129,22 +167,28 @@ func (i *GetImagesInfo) getAllImagesInfo() ([]*ImageInfo, error) {
 				},
 				ImageLayerIDS: *imagelayerIds,
 				ImageID:       image.ID,
+				ParentID:      image.ParentID,
+				Created:       image.Created,
+				RepoDigests:   image.RepoDigests,
 				ImagesHistory: imagesHistory,
 			})
 			continue
 		}
 		for _, imageTag := range image.RepoTags {
-			split := strings.Split(imageTag, ":")
-			if len(split) < 2 {
+			name, tag := splitImageTag(imageTag)
+			if tag == "" {
 				continue
 			}
 			imagesInfo = append(imagesInfo, &ImageInfo{
 				ImageNameData: ImageNameData{
-					ImageName: split[0],
-					ImageTag:  split[1],
+					ImageName: name,
+					ImageTag:  tag,
 				},
 				ImageLayerIDS: *imagelayerIds,
 				ImageID:       image.ID,
+				ParentID:      image.ParentID,
+				Created:       image.Created,
+				RepoDigests:   image.RepoDigests,
 				ImagesHistory: imagesHistory,
 			})
 		}
@@ -159,7 +203,7 @@ func (i *GetImagesInfo) imageContentAddress(diffIds []string) (*[]*ImageLayerID,
 	chainID := ""
 	for _, diffID := range diffIds {
 		if firstLayer { // 首层
-			b, err := ioutil.ReadFile("/var/lib/docker/image/overlay2/layerdb/" + strings.ReplaceAll(diffID, ":", "/") + "/cache-id")
+			b, err := ioutil.ReadFile(layerdbPath + strings.ReplaceAll(diffID, ":", "/") + "/cache-id")
 			if err != nil {
 				log.Fatal(err)
 				return nil, err
@@ -176,7 +220,7 @@ func (i *GetImagesInfo) imageContentAddress(diffIds []string) (*[]*ImageLayerID,
 		// 上层
 		enc := chainID + " " + diffID
 		chain := fmt.Sprintf("%x", sha256.Sum256([]byte(enc)))
-		b, err := ioutil.ReadFile("/var/lib/docker/image/overlay2/layerdb/" + "sha256/" + chain + "/cache-id")
+		b, err := ioutil.ReadFile(layerdbPath + "sha256/" + chain + "/cache-id")
 		if err != nil {
 			log.Fatal(err)
 			return nil, err
@@ -203,19 +247,25 @@ func (i *GetImagesInfo) ImageInfoFromLayerId(layerId string) []*ImageInfo {
 	return imagesInfo
 }
 
+// splitImageTag 按最后一个 / 之后的最后一个 : 拆分镜像名称与 TAG, 没有 TAG 时返回空
+// localhost:5000/app 中的 : 为仓库端口
+func splitImageTag(name string) (string, string) {
+	n := strings.LastIndex(name, ":")
+	if n < 0 || n < strings.LastIndex(name, "/") {
+		return name, ""
+	}
+	return name[:n], name[n+1:]
+}
+
 func (i *GetImagesInfo) ImageInfoFromImageId(imageId string) *ImageInfo {
 	if imageId == "" {
 		return &ImageInfo{}
 	}
 	imageid := strings.ReplaceAll(imageId, "sha256:", "")
-	images := strings.Split(imageid, ":")
-	imageTag := false
-	if len(images) == 2 {
-		imageTag = true
-	}
+	name, tag := splitImageTag(imageid)
 	for _, image := range i.Load() {
-		if imageTag {
-			if image.ImageName == images[0] && image.ImageTag == images[1] {
+		if tag != "" {
+			if image.ImageName == name && image.ImageTag == tag {
 				return image
 			}
 			continue
@@ -238,3 +288,126 @@ func (i *GetImagesInfo) ImageInfoFromNoneImageId(imageId string) *ImageInfo {
 	}
 	return imagesInfo
 }
+
+func (i *GetImagesInfo) ImageGroups() []*ImageGroup {
+	imageGroups := make([]*ImageGroup, 0)
+	groups := make(map[string]*ImageGroup)
+	for _, image := range i.Load() {
+		name := image.ImageName + ":" + image.ImageTag
+		if group, ok := groups[image.ImageID]; ok {
+			group.Names = append(group.Names, name)
+			continue
+		}
+		groups[image.ImageID] = &ImageGroup{
+			ImageInfo: image,
+			Names:     []string{name},
+		}
+		imageGroups = append(imageGroups, groups[image.ImageID])
+	}
+	return imageGroups
+}
+
+// 根据镜像id或镜像:TAG获取镜像信息, 不存在时退出
+func mustImageInfo(imageId string) *ImageInfo {
+	image := GetAllImagesInstance().ImageInfoFromImageId(imageId)
+	if image.ImageID == "" {
+		log.Fatalln("Not found docker image")
+	}
+	return image
+}
+
+// 镜像层大小, 读取 layerdb/sha256/<ChainID>/size
+func layerSize(layer *ImageLayerID) int64 {
+	b, err := ioutil.ReadFile(layerdbPath + "sha256/" + layer.ChainID + "/size")
+	if err != nil {
+		return 0
+	}
+	size, _ := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	return size
+}
+
+// 镜像所属仓库, 来自 镜像名称 及 RepoDigests
+func imageRepositories(image *ImageInfo) map[string]bool {
+	repos := make(map[string]bool)
+	if image.ImageName != "<none>" {
+		repos[image.ImageName] = true
+	}
+	for _, digest := range image.RepoDigests {
+		repos[strings.Split(digest, "@")[0]] = true
+	}
+	return repos
+}
+
+// 两个镜像 ChainID 相同的最长前缀层数
+func commonChainPrefix(a, b []*ImageLayerID) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	// ChainID 由下层所有 DiffID 计算得到, 某层相同即表示以下各层都相同
+	for ; n > 0; n-- {
+		if a[n-1].ChainID == b[n-1].ChainID {
+			return n
+		}
+	}
+	return 0
+}
+
+// imageLayerCreatedBy 每个镜像层对应的 Dockerfile 指令
+func imageLayerCreatedBy(image *ImageInfo) []string {
+	createdBy := make([]string, len(image.ImageLayerIDS))
+	for idx, history := range imageLayerHistory(image) {
+		createdBy[idx] = history.CreatedBy
+	}
+	return createdBy
+}
+
+// 镜像每层 history 中的创建时间, 镜像配置中的 created 只是最后一步的时间
+func imageLayerCreated(image *ImageInfo) []int64 {
+	created := make([]int64, len(image.ImageLayerIDS))
+	for idx, history := range imageLayerHistory(image) {
+		created[idx] = history.Created.Unix()
+	}
+	return created
+}
+
+type layerHistory struct {
+	Created    time.Time `json:"created"`
+	CreatedBy  string    `json:"created_by"`
+	EmptyLayer bool      `json:"empty_layer"`
+}
+
+// 读取 imagedb 中镜像配置的 history, 跳过 empty_layer 记录, 与 ImageLayerIDS 一一对应
+func imageLayerHistory(image *ImageInfo) []*layerHistory {
+	histories := make([]*layerHistory, 0, len(image.ImageLayerIDS))
+	b, err := ioutil.ReadFile(imagedbPath + strings.ReplaceAll(image.ImageID, "sha256:", ""))
+	if err != nil {
+		return histories
+	}
+	config := struct {
+		History []*layerHistory `json:"history"`
+	}{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return histories
+	}
+	for _, history := range config.History {
+		if history.EmptyLayer {
+			continue
+		}
+		if len(histories) >= len(image.ImageLayerIDS) {
+			break
+		}
+		histories = append(histories, history)
+	}
+	return histories
+}
+
+// 镜像层在镜像中的序号
+func imageLayerIndex(image *ImageInfo, cacheID string) int {
+	for idx, layer := range image.ImageLayerIDS {
+		if layer.CacheID == cacheID {
+			return idx
+		}
+	}
+	return -1
+}
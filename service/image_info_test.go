@@ -0,0 +1,53 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitImageTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantName string
+		wantTag  string
+	}{
+		{"alpine", "alpine", ""},
+		{"alpine:3.18", "alpine", "3.18"},
+		{"localhost:5000/app", "localhost:5000/app", ""},
+		{"localhost:5000/app:1.0", "localhost:5000/app", "1.0"},
+		{"3966e280acf1", "3966e280acf1", ""},
+	}
+	for _, tt := range tests {
+		name, tag := splitImageTag(tt.name)
+		if name != tt.wantName || tag != tt.wantTag {
+			t.Errorf("splitImageTag(%q) = %q, %q, want %q, %q", tt.name, name, tag, tt.wantName, tt.wantTag)
+		}
+	}
+}
+
+func TestImageInfoFromImageId(t *testing.T) {
+	images := []*ImageInfo{
+		{ImageNameData: ImageNameData{ImageName: "localhost:5000/app", ImageTag: "1.0"}, ImageID: "sha256:" + strings.Repeat("1", 64)},
+		{ImageNameData: ImageNameData{ImageName: "app", ImageTag: "1.0"}, ImageID: "sha256:" + strings.Repeat("2", 64)},
+	}
+	getAllImagesData = &GetImagesInfo{}
+	getAllImagesData.cache.Store(map[string][]*ImageInfo{"ImagesInfo": images})
+	t.Cleanup(func() {
+		getAllImagesData = nil
+	})
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"localhost:5000/app:1.0", images[0].ImageID},
+		{"app:1.0", images[1].ImageID},
+		{"222222222222", images[1].ImageID},
+		{"sha256:" + strings.Repeat("1", 64), images[0].ImageID},
+		{"app:2.0", ""},
+	}
+	for _, tt := range tests {
+		if got := GetAllImagesInstance().ImageInfoFromImageId(tt.ref).ImageID; got != tt.want {
+			t.Errorf("ImageInfoFromImageId(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
@@ -7,17 +7,106 @@ type ImageInterface interface {
 	// 根据none标记的镜像, 比较镜像层数最贴近的 "镜像名称:TAG"
 	ContainsNoneLayerImage()
 
+	// 所有none标记的镜像, 比较镜像层数最贴近的 "镜像名称:TAG"
+	ContainsNoneLayerImages()
+
 	// 包含匹配文件的镜像
 	ContainsBinaryfile()
 
-	// 根据镜像层id, 找出所关联的镜像
+	// 根据镜像层id, 找出所关联的镜像及容器
 	ContainsImageLayerID()
 
 	// 镜像层内容
 	ImageLayerContent()
+
+	// 镜像合并视图, 列出目录内容
+	MergedList()
+
+	// 镜像合并视图, 输出文件内容
+	MergedCat()
+
+	// 镜像合并视图, 显示文件信息
+	MergedStat()
+
+	// 根据 tar-split 重新计算 DiffID, 校验镜像层完整性
+	VerifyImageLayer()
+
+	// 未被镜像及容器引用的 overlay2 目录、失效短链接
+	OrphanLayer()
+
+	// 检查镜像层 link、lower 短链接与 ChainID 顺序是否一致
+	CheckLowerChain()
+
+	// 容器与镜像、读写层的对应关系
+	ContainerLayer()
+
+	// 容器读写层相对镜像修改的文件
+	ContainerChanges()
+
+	// 镜像父子关系树
+	ImageTree()
+
+	// 导出镜像层关系图 dot | mermaid
+	ImageGraph()
+
+	// 镜像之间共享层矩阵, 独占及共享空间统计
+	LayerShareReport()
+
+	// 删除镜像前计算可释放空间
+	PlanRemove()
+
+	// none 标记镜像分类, 删除可以安全删除的镜像
+	PruneNoneImage()
+
+	// 记录 镜像:TAG 与镜像ID 的对应关系及镜像事件
+	RecordTagHistory()
+
+	// 镜像:TAG 曾经指向过的镜像ID
+	TagHistory()
+
+	// 按文件名或路径通配符查找所有镜像层中的文件
+	FindImageFile()
+
+	// 按正则表达式搜索所有镜像层中文本文件的内容
+	GrepImageFile()
+
+	// 更新文件摘要索引, 只计算新增镜像层
+	UpdateFileIndex()
+
+	// 按 ELF build-id 或 Go 模块信息查找可执行文件
+	FindBinary()
+
+	// 镜像中安装的 apk、dpkg、rpm 包及安装该包的镜像层
+	ImagePackages()
+
+	// 生成 SPDX 或 CycloneDX 格式的 SBOM
+	ImageSBOM()
+
+	// 根据本地 OSV 漏洞库按镜像及镜像层显示漏洞
+	ImageVulnerability()
+
+	// 检查所有镜像层中的敏感信息, 包括在上层已删除的文件
+	SecretScan()
 }
 
 type ImageRelation struct {
-	ImageId   string `json:"image_id"`   // 镜像层ID, 镜像ID, 镜像TAG
-	ImageFile string `json:"image_file"` // 镜像文件
+	ImageId       string   `json:"image_id"`       // 镜像层ID, 镜像ID, 镜像TAG
+	ImageFile     string   `json:"image_file"`     // 镜像文件
+	FileHash      string   `json:"file_hash"`      // 文件摘要, 格式 算法:摘要
+	HashAlgorithm string   `json:"hash_algorithm"` // 摘要算法 sha256 | blake3 | xxhash | md5
+	ImagePath     string   `json:"image_path"`     // 镜像内路径
+	Format        string   `json:"format"`         // 输出格式
+	Pattern       string   `json:"pattern"`        // 过滤条件
+	ImageRefs     []string `json:"image_refs"`     // 多个镜像ID或镜像:TAG
+	Delete        bool     `json:"delete"`         // 执行删除, 默认只显示
+	NamePattern   string   `json:"name_pattern"`   // 文件名通配符
+	PathPattern   string   `json:"path_pattern"`   // 镜像内路径通配符, 支持 **
+	MaxFileSize   int64    `json:"max_file_size"`  // 搜索文件大小上限, 0 不限制
+	UseIndex      bool     `json:"use_index"`      // 使用文件摘要索引查找
+	Workers       int      `json:"workers"`        // 文件扫描并发数
+	IOLimit       int64    `json:"io_limit"`       // 文件读取速率限制, 每秒字节数, 0 不限制
+	LowPriority   bool     `json:"low_priority"`   // 以最低 I/O 及 CPU 优先级运行
+	Progress      bool     `json:"progress"`       // 扫描进度输出到 stderr
+	StoragePath   bool     `json:"storage_path"`   // 输出 overlay2 中的实际存储路径
+	AdvisoryDB    string   `json:"advisory_db"`    // 本地 OSV 漏洞库路径
 }
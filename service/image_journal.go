@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bufio"
+	"docker-image/model"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 镜像:TAG 变化记录文件, 由 SetDataRoot 设置为 <data-root>-image/tag-history.jsonl
+var tagJournalPath = "/var/lib/docker-image/tag-history.jsonl"
+
+type TagJournalEntry struct {
+	Time    int64  `json:"time"`     // 记录时间
+	Action  string `json:"action"`   // scan | tag | untag | pull | delete ...
+	Name    string `json:"name"`     // 镜像:TAG
+	ImageID string `json:"image_id"` // 镜像ID, 为空表示 TAG 已不存在
+}
+
+func readTagJournal() ([]*TagJournalEntry, error) {
+	entries := make([]*TagJournalEntry, 0)
+	f, err := os.Open(tagJournalPath)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := &TagJournalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// RecordTagHistory 记录本次运行时 镜像:TAG 与镜像ID 的对应关系, 以及上次运行后 daemon 的镜像事件
+func (i ImageRelation) RecordTagHistory() {
+	entries, err := readTagJournal()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// 上次记录的对应关系
+	var since int64
+	last := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Time > since {
+			since = entry.Time
+		}
+		if entry.Action == "scan" {
+			last[entry.Name] = entry.ImageID
+		}
+	}
+	// 事件时间精确到秒, 从上次记录的同一秒开始查询, 已记录过的事件跳过
+	recorded := make(map[TagJournalEntry]bool)
+	for _, entry := range entries {
+		if entry.Time == since && entry.Action != "scan" {
+			recorded[*entry] = true
+		}
+	}
+	records := make([]*TagJournalEntry, 0)
+	messages, err := model.DockerInstance.ImageEvents(since)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, message := range messages {
+		record := &TagJournalEntry{
+			Time:    message.Time,
+			Action:  string(message.Action),
+			Name:    message.Actor.Attributes["name"],
+			ImageID: message.Actor.ID,
+		}
+		if recorded[*record] {
+			continue
+		}
+		recorded[*record] = true
+		records = append(records, record)
+	}
+	now := time.Now().Unix()
+	current := make(map[string]bool)
+	for _, image := range GetAllImagesInstance().Load() {
+		if isNoneImage(image) {
+			continue
+		}
+		name := image.ImageName + ":" + image.ImageTag
+		current[name] = true
+		if last[name] == image.ImageID {
+			continue
+		}
+		records = append(records, &TagJournalEntry{Time: now, Action: "scan", Name: name, ImageID: image.ImageID})
+	}
+	for name, imageId := range last {
+		if imageId != "" && !current[name] {
+			records = append(records, &TagJournalEntry{Time: now, Action: "scan", Name: name})
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(tagJournalPath), 0755); err != nil {
+		log.Fatalln(err)
+	}
+	f, err := os.OpenFile(tagJournalPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// TagHistory 镜像:TAG 曾经指向过的镜像ID
+func (i ImageRelation) TagHistory() {
+	entries, err := readTagJournal()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	name := i.ImageId
+	if _, tag := splitImageTag(name); tag == "" {
+		name = name + ":latest"
+	}
+	// 曾经指向过的镜像ID, 用于匹配 untag、delete 事件
+	imageIds := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Name == name && entry.ImageID != "" {
+			imageIds[entry.ImageID] = true
+		}
+	}
+	history := make([]*TagJournalEntry, 0)
+	for _, entry := range entries {
+		if entry.Name == name || (entry.Action != "scan" && imageIds[entry.ImageID]) {
+			history = append(history, entry)
+		}
+	}
+	outputTagHistory(history, GetAllImagesInstance().ImageInfoFromImageId(name).ImageID)
+}
+
+func outputTagHistory(data []*TagJournalEntry, currentId string) {
+	format := "%-19s %-8s %-12s %-7s %s\n"
+	fmt.Fprintf(os.Stdout, format, "TIME", "ACTION", "IMAGE ID", "CURRENT", "NAME")
+	for _, v := range data {
+		imageId := strings.ReplaceAll(v.ImageID, "sha256:", "")
+		if len(imageId) > 12 {
+			imageId = imageId[:12]
+		}
+		current := ""
+		if v.ImageID != "" && v.ImageID == currentId {
+			current = "*"
+		}
+		fmt.Fprintf(os.Stdout, format, time.Unix(v.Time, 0).Format("2006-01-02 15:04:05"), v.Action, imageId, current, v.Name)
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type LowerCheckData struct {
+	ImageLayerID
+	Problem string `json:"problem"` // 问题描述
+}
+
+type LowerImageData struct {
+	ImageNameData
+	ImageID string `json:"image_id"` // 镜像ID
+	ChainID string `json:"chain_id"` // 存在问题的层
+}
+
+// 读取 overlay2/<cache-id>/link 短链接名称
+func overlay2ShortLink(cacheID string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(overlay2Path, cacheID, "link"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// 检查 l/<shortid> 短链接是否指向 cache-id 的 diff 目录
+func checkShortLink(shortID, cacheID string) string {
+	linkPath := filepath.Join(overlay2Path, "l", shortID)
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		return "l/" + shortID + ": broken link"
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(linkPath), target)
+	}
+	if _, err := os.Stat(target); err != nil {
+		return "l/" + shortID + ": broken link"
+	}
+	if cacheID != "" && target != filepath.Join(overlay2Path, cacheID, "diff") {
+		return "l/" + shortID + ": points to " + target
+	}
+	return ""
+}
+
+// checkLowerChain 检查镜像第 idx 层的 link 与 lower 文件
+// lower 文件按由近及远的顺序记录下层短链接, 需与 ChainID 顺序一致
+func checkLowerChain(layers []*ImageLayerID, idx int) []string {
+	problems := make([]string, 0)
+	layer := layers[idx]
+	shortID, err := overlay2ShortLink(layer.CacheID)
+	if err != nil {
+		problems = append(problems, "link: "+err.Error())
+	} else if problem := checkShortLink(shortID, layer.CacheID); problem != "" {
+		problems = append(problems, problem)
+	}
+	b, err := os.ReadFile(filepath.Join(overlay2Path, layer.CacheID, "lower"))
+	if idx == 0 {
+		if err == nil {
+			problems = append(problems, "lower: unexpected lower file in base layer")
+		}
+		return problems
+	}
+	if err != nil {
+		return append(problems, "lower: "+err.Error())
+	}
+	lowers := strings.Split(strings.TrimSpace(string(b)), ":")
+	if len(lowers) != idx {
+		problems = append(problems, fmt.Sprintf("lower: %d entries, expected %d", len(lowers), idx))
+	}
+	for n, lower := range lowers {
+		shortID := strings.TrimPrefix(lower, "l/")
+		expectedCacheID := ""
+		if idx-1-n >= 0 {
+			expectedCacheID = layers[idx-1-n].CacheID
+		}
+		if problem := checkShortLink(shortID, expectedCacheID); problem != "" {
+			problems = append(problems, "lower: "+problem)
+		}
+	}
+	return problems
+}
+
+func (i ImageRelation) CheckLowerChain() {
+	images := GetAllImagesInstance().Load()
+	if i.ImageId != "" {
+		images = []*ImageInfo{mustImageInfo(i.ImageId)}
+	}
+	// 相同 ChainID 的层只检查一次
+	problems := make(map[string][]string)
+	layers := make(map[string]*ImageLayerID)
+	for _, image := range images {
+		for idx, layer := range image.ImageLayerIDS {
+			if _, ok := problems[layer.ChainID]; ok {
+				continue
+			}
+			problems[layer.ChainID] = checkLowerChain(image.ImageLayerIDS, idx)
+			layers[layer.ChainID] = layer
+		}
+	}
+	lowerChecksData := make([]*LowerCheckData, 0)
+	for chainID, layerProblems := range problems {
+		layer := layers[chainID]
+		for _, problem := range layerProblems {
+			lowerChecksData = append(lowerChecksData, &LowerCheckData{
+				ImageLayerID: ImageLayerID{
+					DiffID:  layer.DiffID[:12],
+					ChainID: layer.ChainID[:12],
+					CacheID: layer.CacheID[:12],
+				},
+				Problem: problem,
+			})
+		}
+	}
+	sort.SliceStable(lowerChecksData, func(a, b int) bool {
+		return lowerChecksData[a].ChainID < lowerChecksData[b].ChainID
+	})
+	outputLowerCheck(lowerChecksData)
+	if len(lowerChecksData) == 0 {
+		return
+	}
+
+	// 受影响的镜像
+	lowerImagesData := make([]*LowerImageData, 0)
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	for _, image := range images {
+		for _, layer := range image.ImageLayerIDS {
+			if len(problems[layer.ChainID]) == 0 {
+				continue
+			}
+			lowerImagesData = append(lowerImagesData, &LowerImageData{
+				ImageNameData: ImageNameData{
+					ImageName: image.ImageName,
+					ImageTag:  image.ImageTag,
+				},
+				ImageID: strings.ReplaceAll(image.ImageID, "sha256:", "")[:12],
+				ChainID: layer.ChainID[:12],
+			})
+			if len(image.ImageName) > repoSize {
+				repoSize = len(image.ImageName)
+			}
+			if len(image.ImageTag) > tagSize {
+				tagSize = len(image.ImageTag)
+			}
+		}
+	}
+	fmt.Fprintln(os.Stdout)
+	outputLowerImage(lowerImagesData, strconv.Itoa(repoSize), strconv.Itoa(tagSize))
+	os.Exit(1)
+}
+
+func outputLowerCheck(data []*LowerCheckData) {
+	format := "%-14s %-14s %-14s %s\n"
+	fmt.Fprintf(os.Stdout, format, "DIFF ID", "CHAIN ID", "CACHE ID", "PROBLEM")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.DiffID, v.ChainID, v.CacheID, v.Problem)
+	}
+}
+
+func outputLowerImage(data []*LowerImageData, repoSize, tagSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %s\n", "1111", repoSize), "9999", tagSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "CHAIN ID")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, v.ChainID)
+	}
+}
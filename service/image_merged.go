@@ -0,0 +1,293 @@
+package service
+
+import (
+	"docker-image/util"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type MergedEntry struct {
+	Layer    int         `json:"layer"`     // 所在镜像层序号
+	DiffID   string      `json:"diff_id"`   // 所在镜像层 DiffID
+	HostPath string      `json:"host_path"` // overlay2 实际存储路径
+	Info     os.FileInfo `json:"-"`
+}
+
+type MergedFileData struct {
+	Name        string `json:"name"`         // 镜像内路径或文件名
+	Mode        string `json:"mode"`         // 文件权限
+	Size        string `json:"size"`         // 文件大小
+	UID         string `json:"uid"`          // 属主
+	GID         string `json:"gid"`          // 属组
+	Modified    string `json:"modified"`     // 修改时间
+	Layer       string `json:"layer"`        // 镜像层序号
+	DiffID      string `json:"diff_id"`      // 镜像层 DiffID
+	StoragePath string `json:"storage_path"` // 存储路径
+}
+
+// 镜像内路径按目录拆分, 根目录返回空
+func splitImagePath(path string) []string {
+	path = strings.Trim(filepath.Clean("/"+path), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// mergedLayers 自顶向下查找路径在各镜像层中的实例
+// 遇到 whiteout、opaque 目录或非目录文件时停止向下查找
+func mergedLayers(image *ImageInfo, path string) []*MergedEntry {
+	comps := splitImagePath(path)
+	entries := make([]*MergedEntry, 0)
+	for idx := len(image.ImageLayerIDS) - 1; idx >= 0; idx-- {
+		layer := image.ImageLayerIDS[idx]
+		dir := filepath.Join(overlay2Path, layer.CacheID, "diff")
+		hidden, opaque, absent := false, false, false
+		for n, comp := range comps {
+			if _, err := os.Lstat(filepath.Join(dir, util.WhiteoutPrefix+comp)); err == nil {
+				hidden = true
+				break
+			}
+			info, err := os.Lstat(filepath.Join(dir, comp))
+			if err != nil {
+				absent = true
+				break
+			}
+			if util.IsWhiteout(info) || (n < len(comps)-1 && !info.IsDir()) {
+				hidden = true
+				break
+			}
+			dir = filepath.Join(dir, comp)
+			if info.IsDir() && util.IsOpaqueDir(dir) {
+				opaque = true
+			}
+		}
+		if hidden {
+			break
+		}
+		if !absent {
+			info, err := os.Lstat(dir)
+			if err != nil {
+				break
+			}
+			entries = append(entries, &MergedEntry{
+				Layer:    idx,
+				DiffID:   layer.DiffID,
+				HostPath: dir,
+				Info:     info,
+			})
+			if !info.IsDir() {
+				break
+			}
+		}
+		if opaque {
+			break
+		}
+	}
+	return entries
+}
+
+// resolveMergedPath 解析路径中的符号链接, follow 表示是否解析最后一级
+func resolveMergedPath(image *ImageInfo, path string, follow bool) (string, error) {
+	comps := splitImagePath(path)
+	resolved := "/"
+	links := 0
+	for n := 0; n < len(comps); n++ {
+		next := filepath.Join(resolved, comps[n])
+		if n == len(comps)-1 && !follow {
+			resolved = next
+			break
+		}
+		entries := mergedLayers(image, next)
+		if len(entries) == 0 || entries[0].Info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		links += 1
+		if links > 40 {
+			return "", errors.New(path + ": too many levels of symbolic links")
+		}
+		target, err := os.Readlink(entries[0].HostPath)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(resolved, target)
+		}
+		// 链接目标替换已解析部分, 重新解析
+		comps = append(splitImagePath(target), comps[n+1:]...)
+		resolved = "/"
+		n = -1
+	}
+	return resolved, nil
+}
+
+// mergedLookup 查找镜像合并视图中的路径
+func mergedLookup(image *ImageInfo, path string, follow bool) (string, []*MergedEntry, error) {
+	resolved, err := resolveMergedPath(image, path, follow)
+	if err != nil {
+		return "", nil, err
+	}
+	entries := mergedLayers(image, resolved)
+	if len(entries) == 0 {
+		return "", nil, errors.New(path + ": no such file or directory")
+	}
+	return resolved, entries, nil
+}
+
+func mergedFileData(name string, entry *MergedEntry) *MergedFileData {
+	info := entry.Info
+	if info.Mode()&os.ModeSymlink != 0 {
+		if target, err := os.Readlink(entry.HostPath); err == nil {
+			name = name + " -> " + target
+		}
+	}
+	uid, gid := "", ""
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		uid, gid = strconv.Itoa(int(stat.Uid)), strconv.Itoa(int(stat.Gid))
+	}
+	return &MergedFileData{
+		Name:        name,
+		Mode:        info.Mode().String(),
+		Size:        strconv.FormatInt(info.Size(), 10),
+		UID:         uid,
+		GID:         gid,
+		Modified:    info.ModTime().Format("2006-01-02 15:04:05"),
+		Layer:       strconv.Itoa(entry.Layer),
+		DiffID:      entry.DiffID[:12],
+		StoragePath: entry.HostPath,
+	}
+}
+
+// mergedDirEntries 合并目录在各镜像层中的实例, 返回合并视图中的目录项
+// 上层覆盖下层, whiteout 删除下层同名文件
+func mergedDirEntries(entries []*MergedEntry) map[string]*MergedEntry {
+	files := make(map[string]*MergedEntry)
+	deleted := make(map[string]bool)
+	for _, entry := range entries {
+		dirEntries, err := os.ReadDir(entry.HostPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, dirEntry := range dirEntries {
+			name := dirEntry.Name()
+			if name == util.WhiteoutOpaqueDir {
+				continue
+			}
+			if strings.HasPrefix(name, util.WhiteoutPrefix) {
+				deleted[strings.TrimPrefix(name, util.WhiteoutPrefix)] = true
+				continue
+			}
+			info, err := dirEntry.Info()
+			if err != nil {
+				continue
+			}
+			if util.IsWhiteout(info) {
+				deleted[name] = true
+				continue
+			}
+			if _, ok := files[name]; ok || deleted[name] {
+				continue
+			}
+			files[name] = &MergedEntry{
+				Layer:    entry.Layer,
+				DiffID:   entry.DiffID,
+				HostPath: filepath.Join(entry.HostPath, name),
+				Info:     info,
+			}
+		}
+	}
+	return files
+}
+
+// mergedListLookup 与 ls 一致, 最后一级为指向目录的符号链接(如 /lib -> usr/lib)时列出目标目录
+// 指向文件或无效的符号链接显示链接本身
+func mergedListLookup(image *ImageInfo, path string) (string, []*MergedEntry, error) {
+	resolved, entries, err := mergedLookup(image, path, false)
+	if err != nil || entries[0].Info.Mode()&os.ModeSymlink == 0 {
+		return resolved, entries, err
+	}
+	if target, targetEntries, err := mergedLookup(image, path, true); err == nil && targetEntries[0].Info.IsDir() {
+		return target, targetEntries, nil
+	}
+	return resolved, entries, nil
+}
+
+func (i ImageRelation) MergedList() {
+	image := mustImageInfo(i.ImageId)
+	path, entries, err := mergedListLookup(image, i.ImagePath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !entries[0].Info.IsDir() {
+		outputMergedList([]*MergedFileData{mergedFileData(path, entries[0])})
+		return
+	}
+	files := mergedDirEntries(entries)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mergedFilesData := make([]*MergedFileData, 0, len(names))
+	for _, name := range names {
+		mergedFilesData = append(mergedFilesData, mergedFileData(name, files[name]))
+	}
+	outputMergedList(mergedFilesData)
+}
+
+func (i ImageRelation) MergedCat() {
+	image := mustImageInfo(i.ImageId)
+	_, entries, err := mergedLookup(image, i.ImagePath, true)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !entries[0].Info.Mode().IsRegular() {
+		log.Fatalln(i.ImagePath + ": not a regular file")
+	}
+	f, err := os.Open(entries[0].HostPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func (i ImageRelation) MergedStat() {
+	image := mustImageInfo(i.ImageId)
+	path, entries, err := mergedLookup(image, i.ImagePath, false)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	outputMergedStat(mergedFileData(path, entries[0]))
+}
+
+func outputMergedList(data []*MergedFileData) {
+	format := "%-11s %-6s %-6s %-12s %-19s %-5s %-12s %s\n"
+	fmt.Fprintf(os.Stdout, format, "MODE", "UID", "GID", "SIZE", "MODIFIED", "LAYER", "DIFF ID", "NAME")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.Mode, v.UID, v.GID, v.Size, v.Modified, v.Layer, v.DiffID, v.Name)
+	}
+}
+
+func outputMergedStat(v *MergedFileData) {
+	format := "%-12s %s\n"
+	fmt.Fprintf(os.Stdout, format, "PATH:", v.Name)
+	fmt.Fprintf(os.Stdout, format, "MODE:", v.Mode)
+	fmt.Fprintf(os.Stdout, format, "UID/GID:", v.UID+"/"+v.GID)
+	fmt.Fprintf(os.Stdout, format, "SIZE:", v.Size)
+	fmt.Fprintf(os.Stdout, format, "MODIFIED:", v.Modified)
+	fmt.Fprintf(os.Stdout, format, "LAYER:", v.Layer)
+	fmt.Fprintf(os.Stdout, format, "DIFF ID:", v.DiffID)
+	fmt.Fprintf(os.Stdout, format, "STORAGE:", v.StoragePath)
+}
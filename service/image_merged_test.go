@@ -0,0 +1,58 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMergedListLookup(t *testing.T) {
+	dataRoot := setupOverlay2(t)
+	diff := filepath.Join(dataRoot, "overlay2/cache0/diff")
+	links := map[string]string{
+		"lib":         "usr/bin",
+		"bin/tool":    "/usr/bin/tool",
+		"bin/missing": "../nonexistent",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(diff, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// usr/bin 只在 app:1.0 的 layer1 中
+	image := GetAllImagesInstance().ImageInfoFromImageId("app:1.0")
+	tests := []struct {
+		path     string
+		wantPath string
+		wantDir  bool
+	}{
+		{"/lib", "/usr/bin", true},
+		{"/lib/", "/usr/bin", true},
+		{"/bin", "/bin", true},
+		{"/bin/tool", "/bin/tool", false},
+		{"/bin/missing", "/bin/missing", false},
+	}
+	for _, tt := range tests {
+		path, entries, err := mergedListLookup(image, tt.path)
+		if err != nil {
+			t.Fatalf("mergedListLookup(%q): %v", tt.path, err)
+		}
+		if path != tt.wantPath || entries[0].Info.IsDir() != tt.wantDir {
+			t.Errorf("mergedListLookup(%q) = %q, dir %v, want %q, dir %v", tt.path, path, entries[0].Info.IsDir(), tt.wantPath, tt.wantDir)
+		}
+	}
+	_, entries, err := mergedListLookup(image, "/lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for name := range mergedDirEntries(entries) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"other", "tool"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("mergedDirEntries(/lib) = %v, want %v", names, want)
+	}
+}
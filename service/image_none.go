@@ -0,0 +1,88 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type NoneImageData struct {
+	ImageNameData
+	ImageID     string `json:"image_id"`    // 镜像ID
+	Created     string `json:"created"`     // 创建时间
+	Size        int64  `json:"size"`        // 镜像大小
+	Reclaimable int64  `json:"reclaimable"` // 单独删除可释放空间
+	Match       string `json:"match"`       // 最接近的 镜像:TAG
+	Layers      int    `json:"layers"`      // 相同层数
+	Ratio       string `json:"ratio"`       // 相同层数占比
+}
+
+// reclaimableSize 单独删除镜像可以释放的空间
+func reclaimableSize(image *ImageInfo, groups []*ImageGroup, usedBy map[string][]string) int64 {
+	_, removed := planRemove([]string{image.ImageID}, groups, usedBy)
+	var size int64
+	for _, layer := range planLayers(groups, removed) {
+		if layer.Status == "freed" {
+			size += layer.Size
+		}
+	}
+	return size
+}
+
+// ContainsNoneLayerImages 一次找出所有 none 标记镜像最接近的 "镜像名称:TAG"
+func (i ImageRelation) ContainsNoneLayerImages() {
+	groups := GetAllImagesInstance().ImageGroups()
+	usedBy, err := containersByImageId()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	noneImagesData := make([]*NoneImageData, 0)
+	repoSize := len("REPOSITORY")
+	matchSize := len("MATCH")
+	for _, group := range groups {
+		if !isNoneImage(group.ImageInfo) {
+			continue
+		}
+		data := &NoneImageData{
+			ImageNameData: group.ImageNameData,
+			ImageID:       strings.ReplaceAll(group.ImageID, "sha256:", "")[:12],
+			Created:       util.CreatedSince(group.Created),
+			Size:          layersSize(group.ImageLayerIDS),
+			Reclaimable:   reclaimableSize(group.ImageInfo, groups, usedBy),
+		}
+		closest := closestNamedImages(group.ImageInfo)
+		if len(closest) > 0 {
+			names := make([]string, 0, len(closest))
+			for _, v := range closest {
+				names = append(names, v.ImageName+":"+v.ImageTag)
+			}
+			data.Match = strings.Join(names, ", ")
+			data.Layers = closest[0].Layers
+			data.Ratio = strconv.Itoa(closest[0].Similarity) + "%"
+		}
+		noneImagesData = append(noneImagesData, data)
+		if len(data.ImageName) > repoSize {
+			repoSize = len(data.ImageName)
+		}
+		if len(data.Match) > matchSize {
+			matchSize = len(data.Match)
+		}
+	}
+	sort.SliceStable(noneImagesData, func(a, b int) bool {
+		return noneImagesData[a].Reclaimable > noneImagesData[b].Reclaimable
+	})
+	outputNoneImages(noneImagesData, strconv.Itoa(repoSize), strconv.Itoa(matchSize))
+}
+
+func outputNoneImages(data []*NoneImageData, repoSize, matchSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-6s %-12s %-14s %-10s %-11s %-9999s %-13s %s\n", "1111", repoSize), "9999", matchSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "CREATED", "SIZE", "RECLAIMABLE", "MATCH", "ROOTFS LAYERS", "RATIO")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, v.Created, util.ImageSize(v.Size),
+			util.ImageSize(v.Reclaimable), v.Match, strconv.Itoa(v.Layers), v.Ratio)
+	}
+}
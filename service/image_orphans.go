@@ -0,0 +1,132 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type OrphanData struct {
+	Type  string `json:"type"`  // unreferenced | broken link | missing dir
+	Path  string `json:"path"`  // 路径
+	Size  int64  `json:"size"`  // 可回收大小
+	Refer string `json:"refer"` // 引用来源
+}
+
+// overlay2 目录被 layerdb 中的引用, cache-id、mount-id、init-id
+func overlay2References() (map[string]string, error) {
+	refs := make(map[string]string)
+	layers, err := os.ReadDir(filepath.Join(layerdbPath, "sha256"))
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers {
+		b, err := os.ReadFile(filepath.Join(layerdbPath, "sha256", layer.Name(), "cache-id"))
+		if err != nil {
+			continue
+		}
+		refs[strings.TrimSpace(string(b))] = filepath.Join(layerdbPath, "sha256", layer.Name(), "cache-id")
+	}
+	mounts, err := os.ReadDir(filepath.Join(layerdbPath, "mounts"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, mount := range mounts {
+		for _, name := range []string{"mount-id", "init-id"} {
+			b, err := os.ReadFile(filepath.Join(layerdbPath, "mounts", mount.Name(), name))
+			if err != nil {
+				continue
+			}
+			refs[strings.TrimSpace(string(b))] = filepath.Join(layerdbPath, "mounts", mount.Name(), name)
+		}
+	}
+	return refs, nil
+}
+
+func (i ImageRelation) OrphanLayer() {
+	refs, err := overlay2References()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	dirsEntry, err := os.ReadDir(overlay2Path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	orphansData := make([]*OrphanData, 0)
+	dirs := make(map[string]bool)
+	for _, fd := range dirsEntry {
+		if !fd.IsDir() || fd.Name() == "l" {
+			continue
+		}
+		dirs[fd.Name()] = true
+		if _, ok := refs[fd.Name()]; ok {
+			continue
+		}
+		size, _ := util.DirSize(filepath.Join(overlay2Path, fd.Name()))
+		orphansData = append(orphansData, &OrphanData{
+			Type: "unreferenced",
+			Path: filepath.Join(overlay2Path, fd.Name()),
+			Size: size,
+		})
+	}
+	// l/ 短链接目标不存在
+	links, _ := os.ReadDir(filepath.Join(overlay2Path, "l"))
+	for _, link := range links {
+		linkPath := filepath.Join(overlay2Path, "l", link.Name())
+		target, err := os.Readlink(linkPath)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(linkPath), target)
+		}
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		orphansData = append(orphansData, &OrphanData{
+			Type:  "broken link",
+			Path:  linkPath,
+			Refer: target,
+		})
+	}
+	// layerdb 引用的目录不存在
+	for cacheID, refer := range refs {
+		if dirs[cacheID] {
+			continue
+		}
+		orphansData = append(orphansData, &OrphanData{
+			Type:  "missing dir",
+			Path:  filepath.Join(overlay2Path, cacheID),
+			Refer: refer,
+		})
+	}
+	sort.SliceStable(orphansData, func(a, b int) bool {
+		if orphansData[a].Type != orphansData[b].Type {
+			return orphansData[a].Type > orphansData[b].Type
+		}
+		return orphansData[a].Path < orphansData[b].Path
+	})
+	outputOrphan(orphansData)
+}
+
+func outputOrphan(data []*OrphanData) {
+	pathSize := len("PATH")
+	var total int64
+	for _, v := range data {
+		if len(v.Path) > pathSize {
+			pathSize = len(v.Path)
+		}
+		total += v.Size
+	}
+	format := strings.ReplaceAll("%-12s %-1111s %-10s %s\n", "1111", strconv.Itoa(pathSize))
+	fmt.Fprintf(os.Stdout, format, "TYPE", "PATH", "SIZE", "REFERENCE")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.Type, v.Path, util.ImageSize(v.Size), v.Refer)
+	}
+	fmt.Fprintf(os.Stdout, "\nreclaimable: %s\n", util.ImageSize(total))
+}
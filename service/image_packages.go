@@ -0,0 +1,142 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type PackageData struct {
+	*util.Package
+	Layer     int    `json:"layer"`      // 安装该包的镜像层序号
+	DiffID    string `json:"diff_id"`    // 安装该包的镜像层 DiffID
+	CreatedBy string `json:"created_by"` // 创建该层的指令
+}
+
+// layerRemoved 镜像层中是否删除了下层的文件: 文件或上级目录为 whiteout, 或上级目录为 opaque 目录
+func layerRemoved(diffPath, rel string) bool {
+	dir := diffPath
+	for _, name := range strings.Split(rel, "/") {
+		if info, err := os.Lstat(filepath.Join(dir, name)); err == nil && util.IsWhiteout(info) {
+			return true
+		}
+		if _, err := os.Lstat(filepath.Join(dir, util.WhiteoutPrefix+name)); err == nil {
+			return true
+		}
+		dir = filepath.Join(dir, name)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && util.IsOpaqueDir(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// layerPackageDBs 镜像层中新写入的包数据库, 返回 数据库路径 -> 包列表, 被删除的数据库包列表为 nil
+func layerPackageDBs(diffPath string) map[string][]*util.Package {
+	dbs := make(map[string][]*util.Package)
+	parse := func(rel string, parser func(string) ([]*util.Package, error)) {
+		path := filepath.Join(diffPath, rel)
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+			packages, err := parser(path)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			dbs[rel] = packages
+			return
+		}
+		if layerRemoved(diffPath, rel) {
+			dbs[rel] = nil
+		}
+	}
+	parse(util.ApkInstalledPath, util.ParseApkInstalled)
+	parse(util.DpkgStatusPath, util.ParseDpkgStatus)
+	for _, rel := range util.RpmDBPaths {
+		parse(rel, util.ParseRpmDB)
+	}
+	entries, _ := os.ReadDir(filepath.Join(diffPath, util.DpkgStatusDir))
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".md5sums") {
+			continue
+		}
+		parse(filepath.Join(util.DpkgStatusDir, entry.Name()), util.ParseDpkgStatus)
+	}
+	return dbs
+}
+
+// imagePackages 从下往上依次读取每层的包数据库, 包第一次出现或版本变化的层即为安装该包的层
+func imagePackages(image *ImageInfo) []*PackageData {
+	createdBy := imageLayerCreatedBy(image)
+	current := make(map[string]map[string]*PackageData)
+	for idx, layer := range image.ImageLayerIDS {
+		diffPath := filepath.Join(overlay2Path, layer.CacheID, "diff")
+		for rel, packages := range layerPackageDBs(diffPath) {
+			if packages == nil {
+				delete(current, rel)
+				continue
+			}
+			packagesData := make(map[string]*PackageData)
+			for _, pkg := range packages {
+				key := pkg.Type + "/" + pkg.Name + "/" + pkg.Arch
+				if old, ok := current[rel][key]; ok && old.Version == pkg.Version {
+					packagesData[key] = old
+					continue
+				}
+				packagesData[key] = &PackageData{
+					Package:   pkg,
+					Layer:     idx,
+					DiffID:    layer.DiffID,
+					CreatedBy: createdBy[idx],
+				}
+			}
+			current[rel] = packagesData
+		}
+	}
+	packagesData := make([]*PackageData, 0)
+	for _, packages := range current {
+		for _, pkg := range packages {
+			packagesData = append(packagesData, pkg)
+		}
+	}
+	sort.SliceStable(packagesData, func(a, b int) bool {
+		x, y := packagesData[a], packagesData[b]
+		if x.Layer != y.Layer {
+			return x.Layer < y.Layer
+		}
+		if x.Type != y.Type {
+			return x.Type < y.Type
+		}
+		return x.Name < y.Name
+	})
+	return packagesData
+}
+
+// ImagePackages 镜像中安装的 apk、dpkg、rpm 包及安装该包的镜像层
+func (i ImageRelation) ImagePackages() {
+	image := mustImageInfo(i.ImageId)
+	packagesData := imagePackages(image)
+	nameSize := len("NAME")
+	versionSize := len("VERSION")
+	for _, v := range packagesData {
+		if len(v.Name) > nameSize {
+			nameSize = len(v.Name)
+		}
+		if len(v.Version) > versionSize {
+			versionSize = len(v.Version)
+		}
+	}
+	outputPackages(packagesData, strconv.Itoa(nameSize), strconv.Itoa(versionSize))
+}
+
+func outputPackages(data []*PackageData, nameSize, versionSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-4s %-1111s %-9999s %-8s %-5s %-12s %s\n", "1111", nameSize), "9999", versionSize)
+	fmt.Fprintf(os.Stdout, format, "TYPE", "NAME", "VERSION", "ARCH", "LAYER", "DIFF ID", "CREATED BY")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.Type, v.Name, v.Version, v.Arch, strconv.Itoa(v.Layer), v.DiffID[:12], v.CreatedBy)
+	}
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type PlanImageData struct {
+	Name    string `json:"name"`     // 镜像:TAG
+	ImageID string `json:"image_id"` // 镜像ID
+	Action  string `json:"action"`   // untag | delete | delete (parent) | in use | conflict
+}
+
+type PlanLayerData struct {
+	DiffID  string `json:"diff_id"`
+	ChainID string `json:"chain_id"`
+	Size    int64  `json:"size"`   // 层大小
+	Status  string `json:"status"` // freed | pinned
+	Pinned  string `json:"pinned"` // 仍在使用该层的镜像
+}
+
+// 镜像是否没有 镜像:TAG
+func isUntaggedImage(group *ImageGroup) bool {
+	for _, name := range group.Names {
+		if name != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// 容器使用的镜像, 镜像ID -> 容器名称
+func containersByImageId() (map[string][]string, error) {
+	containersInfo, err := getAllContainersInfo()
+	if err != nil {
+		return nil, err
+	}
+	usedBy := make(map[string][]string)
+	for _, container := range containersInfo {
+		usedBy[container.ImageID] = append(usedBy[container.ImageID], container.Name)
+	}
+	return usedBy, nil
+}
+
+// planRemove 模拟 docker rmi, 返回各镜像的处理方式及被删除的镜像
+func planRemove(refs []string, groups []*ImageGroup, usedBy map[string][]string) ([]*PlanImageData, map[string]bool) {
+	byId := make(map[string]*ImageGroup)
+	for _, group := range groups {
+		byId[group.ImageID] = group
+	}
+	// 待删除的 镜像:TAG, 使用镜像ID时删除全部 TAG
+	untags := make(map[string]map[string]bool)
+	byImageId := make(map[string]bool)
+	order := make([]string, 0)
+	for _, ref := range refs {
+		image := mustImageInfo(ref)
+		if _, ok := untags[image.ImageID]; !ok {
+			untags[image.ImageID] = make(map[string]bool)
+			order = append(order, image.ImageID)
+		}
+		if _, tag := splitImageTag(strings.ReplaceAll(ref, "sha256:", "")); tag != "" {
+			untags[image.ImageID][image.ImageName+":"+image.ImageTag] = true
+			continue
+		}
+		byImageId[image.ImageID] = true
+		for _, name := range byId[image.ImageID].Names {
+			untags[image.ImageID][name] = true
+		}
+	}
+	planImagesData := make([]*PlanImageData, 0)
+	removed := make(map[string]bool)
+	for _, imageId := range order {
+		group := byId[imageId]
+		shortId := strings.ReplaceAll(imageId, "sha256:", "")[:12]
+		// 使用镜像ID删除有多个 TAG 的镜像时, docker rmi 需要 -f
+		if byImageId[imageId] && len(group.Names) > 1 {
+			planImagesData = append(planImagesData, &PlanImageData{
+				Name:    strings.Join(group.Names, ", "),
+				ImageID: shortId,
+				Action:  "conflict: image is referenced in multiple repositories (must be forced)",
+			})
+			continue
+		}
+		if len(untags[imageId]) < len(group.Names) {
+			for _, name := range group.Names {
+				if untags[imageId][name] {
+					planImagesData = append(planImagesData, &PlanImageData{Name: name, ImageID: shortId, Action: "untag"})
+				}
+			}
+			continue
+		}
+		if containers, ok := usedBy[imageId]; ok {
+			planImagesData = append(planImagesData, &PlanImageData{
+				Name:    strings.Join(group.Names, ", "),
+				ImageID: shortId,
+				Action:  "in use by container " + strings.Join(containers, ", "),
+			})
+			continue
+		}
+		removed[imageId] = true
+		planImagesData = append(planImagesData, &PlanImageData{Name: strings.Join(group.Names, ", "), ImageID: shortId, Action: "delete"})
+	}
+	// 没有 TAG 且没有其他子镜像的父镜像会一起删除
+	for _, imageId := range order {
+		if !removed[imageId] {
+			continue
+		}
+		for parentId := byId[imageId].ParentID; parentId != ""; {
+			parent, ok := byId[parentId]
+			if !ok || removed[parentId] || !isUntaggedImage(parent) || len(usedBy[parentId]) > 0 {
+				break
+			}
+			children := true
+			for _, group := range groups {
+				if group.ParentID == parentId && !removed[group.ImageID] {
+					children = false
+					break
+				}
+			}
+			if !children {
+				break
+			}
+			removed[parentId] = true
+			planImagesData = append(planImagesData, &PlanImageData{
+				Name:    strings.Join(parent.Names, ", "),
+				ImageID: strings.ReplaceAll(parentId, "sha256:", "")[:12],
+				Action:  "delete (parent)",
+			})
+			parentId = parent.ParentID
+		}
+	}
+	return planImagesData, removed
+}
+
+// planLayers 被删除镜像的层, 没有其他镜像引用时可以释放
+func planLayers(groups []*ImageGroup, removed map[string]bool) []*PlanLayerData {
+	planLayersData := make([]*PlanLayerData, 0)
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		if !removed[group.ImageID] {
+			continue
+		}
+		for _, layer := range group.ImageLayerIDS {
+			if seen[layer.ChainID] {
+				continue
+			}
+			seen[layer.ChainID] = true
+			pinned := make([]string, 0)
+			for _, other := range groups {
+				if removed[other.ImageID] {
+					continue
+				}
+				for _, l := range other.ImageLayerIDS {
+					if l.ChainID == layer.ChainID {
+						pinned = append(pinned, other.Names[0])
+						break
+					}
+				}
+			}
+			status := "freed"
+			if len(pinned) > 0 {
+				status = "pinned"
+			}
+			planLayersData = append(planLayersData, &PlanLayerData{
+				DiffID:  layer.DiffID[:12],
+				ChainID: layer.ChainID[:12],
+				Size:    layerSize(layer),
+				Status:  status,
+				Pinned:  strings.Join(pinned, ", "),
+			})
+		}
+	}
+	return planLayersData
+}
+
+func (i ImageRelation) PlanRemove() {
+	groups := GetAllImagesInstance().ImageGroups()
+	usedBy, err := containersByImageId()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	planImagesData, removed := planRemove(i.ImageRefs, groups, usedBy)
+	outputPlanImage(planImagesData)
+	fmt.Fprintln(os.Stdout)
+	planLayersData := planLayers(groups, removed)
+	outputPlanLayer(planLayersData)
+	var freed, pinned int64
+	for _, v := range planLayersData {
+		if v.Status == "freed" {
+			freed += v.Size
+		} else {
+			pinned += v.Size
+		}
+	}
+	fmt.Fprintf(os.Stdout, "\nfreed: %s, pinned by other images: %s\n", util.ImageSize(freed), util.ImageSize(pinned))
+}
+
+func outputPlanImage(data []*PlanImageData) {
+	nameSize := len("REPOSITORY:TAG")
+	for _, v := range data {
+		if len(v.Name) > nameSize {
+			nameSize = len(v.Name)
+		}
+	}
+	format := strings.ReplaceAll("%-1111s %-12s %s\n", "1111", strconv.Itoa(nameSize))
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY:TAG", "IMAGE ID", "ACTION")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.Name, v.ImageID, v.Action)
+	}
+}
+
+func outputPlanLayer(data []*PlanLayerData) {
+	format := "%-14s %-14s %-10s %-8s %s\n"
+	fmt.Fprintf(os.Stdout, format, "DIFF ID", "CHAIN ID", "SIZE", "STATUS", "PINNED BY")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.DiffID, v.ChainID, util.ImageSize(v.Size), v.Status, v.Pinned)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"docker-image/model"
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type PruneNoneData struct {
+	ImageNameData
+	ImageID string `json:"image_id"` // 镜像ID
+	Size    string `json:"size"`     // 镜像大小
+	Class   string `json:"class"`    // 分类
+	Detail  string `json:"detail"`   // 最接近的 镜像:TAG 或使用镜像的容器
+	Safe    bool   `json:"safe"`     // 是否可以安全删除
+}
+
+// none 标记镜像分类
+const (
+	noneInUse        = "in use"                 // 被容器使用
+	noneParentOfTag  = "parent of tagged image" // 有 TAG 镜像的父镜像
+	noneIntermediate = "intermediate"           // 构建时的中间镜像
+	noneSuperseded   = "superseded"             // TAG 已被新镜像覆盖
+	noneDangling     = "dangling"               // 没有找到相关的 TAG
+)
+
+func isNoneImage(image *ImageInfo) bool {
+	return image.ImageName == "<none>" || image.ImageTag == "<none>"
+}
+
+// 子镜像中是否有带 TAG 的镜像
+func hasTaggedDescendant(imageId string, children map[string][]*ImageGroup) bool {
+	for _, child := range children[imageId] {
+		if !isNoneImage(child.ImageInfo) || hasTaggedDescendant(child.ImageID, children) {
+			return true
+		}
+	}
+	return false
+}
+
+// supersededTags 同一仓库中已指向其他镜像的 镜像:TAG
+// 仓库来自 RepoDigests, 以及 TAG 变化记录中该镜像曾经使用过的 TAG
+func supersededTags(group *ImageGroup, tags map[string]string, journal []*TagJournalEntry) []string {
+	superseded := make(map[string]bool)
+	for _, digest := range group.RepoDigests {
+		repo := strings.Split(digest, "@")[0]
+		for name, imageId := range tags {
+			if imageId != group.ImageID && name[:strings.LastIndex(name, ":")] == repo {
+				superseded[name] = true
+			}
+		}
+	}
+	for _, entry := range journal {
+		if entry.ImageID != group.ImageID {
+			continue
+		}
+		if imageId, ok := tags[entry.Name]; ok && imageId != group.ImageID {
+			superseded[entry.Name] = true
+		}
+	}
+	names := make([]string, 0, len(superseded))
+	for name := range superseded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// classifyNoneImage none 标记镜像分类, 只有没有子镜像且未被容器使用的镜像可以安全删除
+// 只有同一仓库的 TAG 已指向其他镜像时才算被覆盖, 仅有相同的镜像层不能说明镜像已被替代
+func classifyNoneImage(group *ImageGroup, children map[string][]*ImageGroup, usedBy map[string][]string, tags map[string]string, journal []*TagJournalEntry) (string, string, bool) {
+	if containers, ok := usedBy[group.ImageID]; ok {
+		return noneInUse, strings.Join(containers, ", "), false
+	}
+	if hasTaggedDescendant(group.ImageID, children) {
+		return noneParentOfTag, "", false
+	}
+	if len(children[group.ImageID]) > 0 {
+		return noneIntermediate, "", false
+	}
+	if names := supersededTags(group, tags, journal); len(names) > 0 {
+		return noneSuperseded, strings.Join(names, ", "), true
+	}
+	return noneDangling, "", true
+}
+
+func (i ImageRelation) PruneNoneImage() {
+	groups := GetAllImagesInstance().ImageGroups()
+	usedBy, err := containersByImageId()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	journal, err := readTagJournal()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	children := make(map[string][]*ImageGroup)
+	// 当前 镜像:TAG -> 镜像ID
+	tags := make(map[string]string)
+	for _, group := range groups {
+		if group.ParentID != "" {
+			children[group.ParentID] = append(children[group.ParentID], group)
+		}
+		for _, name := range group.Names {
+			if !strings.Contains(name, "<none>") {
+				tags[name] = group.ImageID
+			}
+		}
+	}
+	pruneNonesData := make([]*PruneNoneData, 0)
+	repoSize := len("REPOSITORY")
+	for _, group := range groups {
+		if !isNoneImage(group.ImageInfo) {
+			continue
+		}
+		class, detail, safe := classifyNoneImage(group, children, usedBy, tags, journal)
+		pruneNonesData = append(pruneNonesData, &PruneNoneData{
+			ImageNameData: group.ImageNameData,
+			ImageID:       strings.ReplaceAll(group.ImageID, "sha256:", ""),
+			Size:          util.ImageSize(layersSize(group.ImageLayerIDS)),
+			Class:         class,
+			Detail:        detail,
+			Safe:          safe,
+		})
+		if len(group.ImageName) > repoSize {
+			repoSize = len(group.ImageName)
+		}
+	}
+	sort.SliceStable(pruneNonesData, func(a, b int) bool {
+		return pruneNonesData[a].Safe && !pruneNonesData[b].Safe
+	})
+	outputPruneNone(pruneNonesData, strconv.Itoa(repoSize))
+	if !i.Delete {
+		fmt.Fprintln(os.Stdout, "\ndry run, use -delete to remove safe images")
+		return
+	}
+	fmt.Fprintln(os.Stdout)
+	for _, v := range pruneNonesData {
+		if !v.Safe {
+			continue
+		}
+		if _, err := model.DockerInstance.ImageRemove(v.ImageID); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s %v\n", v.ImageID[:12], err)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "deleted: %s\n", v.ImageID[:12])
+	}
+}
+
+func outputPruneNone(data []*PruneNoneData, repoSize string) {
+	format := strings.ReplaceAll("%-1111s %-6s %-12s %-10s %-5s %-22s %s\n", "1111", repoSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "SIZE", "SAFE", "CLASS", "DETAIL")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID[:12], v.Size, strconv.FormatBool(v.Safe), v.Class, v.Detail)
+	}
+}
@@ -1,13 +1,13 @@
 package service
 
 import (
-	"crypto/md5"
 	"docker-image/util"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,14 +24,20 @@ type HistoryImageStorageData struct {
 
 type ContainsNoneLayerData struct {
 	ImageNameData
-	ImageID string `json:"image_id"` // 镜像ID
-	Layers  int    `json:"layers"`   // 镜像层数
+	ImageID    string `json:"image_id"`   // 镜像ID
+	Layers     int    `json:"layers"`     // 镜像层数
+	Similarity int    `json:"similarity"` // 相同层数占比
+	Created    int64  `json:"created"`    // 创建时间
+	Reason     string `json:"reason"`     // 判断依据
 }
 
 type ContainsBinaryData struct {
 	ImageNameData
-	ImageID  string `json:"image_id"`  // 镜像ID
-	FilePath string `json:"file_path"` // 文件绝对路径
+	ImageID   string `json:"image_id"`   // 镜像ID
+	ImagePath string `json:"image_path"` // 镜像内路径
+	Layer     int    `json:"layer"`      // 镜像层序号
+	CreatedBy string `json:"created_by"` // 创建该层的指令
+	FilePath  string `json:"file_path"`  // 文件绝对路径
 }
 
 type ContainsImageData struct {
@@ -39,6 +45,13 @@ type ContainsImageData struct {
 	ImageID string `json:"image_id"` // 镜像ID
 }
 
+type ContainsContainerData struct {
+	ContainerID string `json:"container_id"` // 容器ID
+	Name        string `json:"name"`         // 容器名称
+	ImageID     string `json:"image_id"`     // 镜像ID
+	State       string `json:"state"`        // 容器状态
+}
+
 type ImageLayerContentData struct {
 	ImageLayerID
 	Content string `json:"content"` // 显示目录及文件名
@@ -51,12 +64,12 @@ type ImageNameData struct {
 }
 
 func (i ImageRelation) ImageFileStorageLocation() {
-	// docker history image = /var/lib/docker/image/overlay2/imagedb/content/sha256
+	// docker history image = <data-root>/image/overlay2/imagedb/content/sha256
 	// docker history image 为镜像id, 默认是本地已有镜像的最后一层
 	// 反查匹配关系
 	// 倒数第一层反向查找 <missing> 标记的层, 及本层与 diff_ids 进行匹配
 	// 除<missing>外的层, 进行查找已有镜像层查找与 diff_ids 进行匹配
-	imageContentPath := "/var/lib/docker/overlay2/"
+	imageContentPath := overlay2Path
 	imageInfo := GetAllImagesInstance().ImageInfoFromImageId(i.ImageId)
 	historyImageStorageDatas := make([]*HistoryImageStorageData, 0)
 	missingNum := 0
@@ -136,151 +149,240 @@ func (i ImageRelation) ImageFileStorageLocation() {
 
 func (i ImageRelation) ContainsNoneLayerImage() {
 	imageInfo := GetAllImagesInstance().ImageInfoFromNoneImageId(i.ImageId)
-	var layerIds []string
-	for _, id := range imageInfo.ImageLayerIDS {
-		layerIds = append(layerIds, id.DiffID)
+	noneLayersData := closestNamedImages(imageInfo)
+	// 最接近镜像层数
+	repoSize := 0
+	tagSize := 0
+	for _, data := range noneLayersData {
+		data.ImageID = strings.ReplaceAll(data.ImageID, "sha256:", "")[:12]
+		if len(data.ImageName) > repoSize {
+			repoSize = len(data.ImageName)
+		}
+		if len(data.ImageTag) > tagSize {
+			tagSize = len(data.ImageTag)
+		}
+	}
+	outputContainsNoneLayer(noneLayersData, strconv.Itoa(repoSize), strconv.Itoa(tagSize))
+}
+
+// closestNamedImages 与 none 标记镜像 ChainID 前缀最长的 "镜像名称:TAG"
+// 相同层数一样时, 优先同一仓库(RepoDigests), 其次按 history 中的创建时间,
+// 优先分叉层在 none 镜像之后创建、时间最接近的镜像
+func closestNamedImages(imageInfo *ImageInfo) []*ContainsNoneLayerData {
+	repos := imageRepositories(imageInfo)
+	var noneCreated int64
+	if created := imageLayerCreated(imageInfo); len(created) > 0 {
+		noneCreated = created[len(created)-1]
 	}
 	maxLayers := 0
 	noneLayersData := make([]*ContainsNoneLayerData, 0)
+	scores := make(map[*ContainsNoneLayerData]int)
+	distances := make(map[*ContainsNoneLayerData]int64)
 	for _, image := range GetAllImagesInstance().Load() {
 		if image.ImageName == "<none>" || image.ImageTag == "<none>" {
 			continue
 		}
-		var iDiffids []string
-		for _, id := range image.ImageLayerIDS {
-			iDiffids = append(iDiffids, id.DiffID)
+		layer := commonChainPrefix(imageInfo.ImageLayerIDS, image.ImageLayerIDS)
+		if layer == 0 || layer < maxLayers {
+			continue
+		}
+		maxLayers = layer
+		total := len(imageInfo.ImageLayerIDS)
+		if len(image.ImageLayerIDS) > total {
+			total = len(image.ImageLayerIDS)
+		}
+		score := 0
+		reasons := make([]string, 0)
+		for repo := range imageRepositories(image) {
+			if repos[repo] {
+				score += 2
+				reasons = append(reasons, "same repository")
+				break
+			}
 		}
-		iDiffids = append(iDiffids, layerIds...)
-		layer := util.FindDuplicates(iDiffids)
-		if layer > maxLayers {
-			maxLayers = layer
+		// 相同层之后的第一层(没有时取最上层)的创建时间
+		var created int64
+		if history := imageLayerCreated(image); layer < len(history) {
+			created = history[layer]
+		} else if len(history) > 0 {
+			created = history[len(history)-1]
 		}
-		noneLayersData = append(noneLayersData, &ContainsNoneLayerData{
+		if created >= noneCreated {
+			score += 1
+			reasons = append(reasons, "created after")
+		}
+		data := &ContainsNoneLayerData{
 			ImageNameData: ImageNameData{
 				ImageName: image.ImageName,
 				ImageTag:  image.ImageTag,
 			},
-			ImageID: image.ImageID,
-			Layers:  layer, // 重复率
-		})
+			ImageID:    image.ImageID,
+			Layers:     layer,
+			Similarity: layer * 100 / total,
+			Created:    image.Created,
+			Reason:     strings.Join(reasons, ", "),
+		}
+		scores[data] = score
+		distances[data] = absInt64(created - noneCreated)
+		noneLayersData = append(noneLayersData, data)
 	}
-	// 最接近镜像层数
-	repoSize := 0
-	tagSize := 0
 	temp := make([]*ContainsNoneLayerData, 0)
 	for _, data := range noneLayersData {
 		if data.Layers == maxLayers {
-			data.ImageID = strings.ReplaceAll(data.ImageID, "sha256:", "")[:12]
 			temp = append(temp, data)
-			if len(data.ImageName) > repoSize {
-				repoSize = len(data.ImageName)
-			}
-			if len(data.ImageTag) > tagSize {
-				tagSize = len(data.ImageTag)
-			}
 		}
 	}
-	noneLayersData = temp
-	outputContainsNoneLayer(noneLayersData, strconv.Itoa(repoSize), strconv.Itoa(tagSize))
+	sort.SliceStable(temp, func(a, b int) bool {
+		if scores[temp[a]] != scores[temp[b]] {
+			return scores[temp[a]] > scores[temp[b]]
+		}
+		return distances[temp[a]] < distances[temp[b]]
+	})
+	return temp
 }
 
-func (i ImageRelation) ContainsBinaryfile() {
-	fileStat, err := os.Stat(i.ImageFile)
-	if err != nil {
-		log.Panicln(err)
+func absInt64(n int64) int64 {
+	if n < 0 {
+		return -n
 	}
-	body, err := os.ReadFile(i.ImageFile)
-	if err != nil {
-		log.Panicln(err)
+	return n
+}
+
+// binaryFileData overlay2 路径对应的镜像、镜像层序号及创建该层的指令
+func binaryFileData(path string, createdBy map[string][]string) []*ContainsBinaryData {
+	datas := make([]*ContainsBinaryData, 0)
+	cacheID, imagePath := splitOverlay2Path(path)
+	if imagePath == "" {
+		return datas
 	}
-	filemd5 := fmt.Sprintf("%x", md5.Sum(body))
-	// TODO: 遍历优化
-	var paths []string
-	dirsEntry, _ := os.ReadDir("/var/lib/docker/overlay2/")
-	dirsNameChan := make(chan string, len(dirsEntry))
-	for _, fd := range dirsEntry {
-		if fd.IsDir() && fd.Name() != "l" {
-			dirsNameChan <- fd.Name()
+	for _, info := range GetAllImagesInstance().ImageInfoFromLayerId(cacheID) {
+		idx := imageLayerIndex(info, cacheID)
+		if idx < 0 {
+			continue
 		}
+		if _, ok := createdBy[info.ImageID]; !ok {
+			createdBy[info.ImageID] = imageLayerCreatedBy(info)
+		}
+		datas = append(datas, &ContainsBinaryData{
+			ImageNameData: ImageNameData{
+				ImageName: info.ImageName,
+				ImageTag:  info.ImageTag,
+			},
+			ImageID:   strings.ReplaceAll(info.ImageID, "sha256:", "")[:12],
+			ImagePath: imagePath,
+			Layer:     idx,
+			CreatedBy: createdBy[info.ImageID][idx],
+			FilePath:  path,
+		})
 	}
-	close(dirsNameChan)
-	tmppathsChan := make(chan string, len(dirsEntry))
-	// 协程遍历处理
-	var w sync.WaitGroup
-	w.Add(10)
-	for i := 0; i < 10; i++ {
-		go func() {
-			defer w.Done()
-			for path := range dirsNameChan {
-				err := filepath.Walk("/var/lib/docker/overlay2/"+path, func(subPath string, info os.FileInfo, err error) error {
-					if err != nil {
-						panic(err)
-					}
-					if !info.IsDir() {
-						if info.Size() == fileStat.Size() {
-							tmppathsChan <- subPath
-						}
-					}
-					return err
-				})
-				if err != nil {
-					panic(err)
+	return datas
+}
+
+// containsBinaryRows 每个 镜像:TAG 及镜像内路径只保留一行(文件所在的最上层), 按镜像名称、路径排序
+// 不检查更上层是否通过 whiteout 删除或以其他内容覆盖了该文件, 结果不一定是容器内可见的文件
+func containsBinaryRows(paths []string) []*ContainsBinaryData {
+	datas := make([]*ContainsBinaryData, 0)
+	rows := make(map[string]*ContainsBinaryData)
+	createdBy := make(map[string][]string)
+	for _, path := range paths {
+		for _, data := range binaryFileData(path, createdBy) {
+			key := data.ImageName + ":" + data.ImageTag + "@" + data.ImageID + data.ImagePath
+			if row, ok := rows[key]; ok {
+				if data.Layer > row.Layer {
+					*row = *data
 				}
+				continue
 			}
-		}()
-	}
-	w.Wait()
-	close(tmppathsChan)
-	for path := range tmppathsChan {
-		paths = append(paths, path)
+			rows[key] = data
+			datas = append(datas, data)
+		}
 	}
+	sort.SliceStable(datas, func(a, b int) bool {
+		x, y := datas[a], datas[b]
+		if x.ImageName != y.ImageName {
+			return x.ImageName < y.ImageName
+		}
+		if x.ImageTag != y.ImageTag {
+			return x.ImageTag < y.ImageTag
+		}
+		if x.ImageID != y.ImageID {
+			return x.ImageID < y.ImageID
+		}
+		return x.ImagePath < y.ImagePath
+	})
+	return datas
+}
 
-	containsBinaryDatas := make([]*ContainsBinaryData, 0)
-	repoSize := 0
-	tagSize := 0
+func (i ImageRelation) ContainsBinaryfile() {
+	// 指定文件时只比较大小相同的文件, 只指定摘要时需要计算所有文件
+	if i.ImageFile != "" && i.FileHash != "" {
+		log.Fatalln("-file and -hash cannot be used together")
+	}
+	algorithm, digest := util.ParseDigest(i.FileHash, i.HashAlgorithm)
+	if _, err := util.NewHash(algorithm); err != nil {
+		log.Fatalln(err)
+	}
+	var fileSize int64 = -1
+	if i.ImageFile != "" {
+		fileStat, err := os.Stat(i.ImageFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fileSize = fileStat.Size()
+		digest, err = util.FileHash(i.ImageFile, algorithm)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	fmt.Fprintf(os.Stdout, "DIGEST %s:%s\n\n", algorithm, digest)
+	sc := i.newScanner()
+	defer sc.close()
+	// 使用索引时直接查找, 不再遍历 overlay2
+	if i.UseIndex {
+		index, err := updateFileIndex(sc, algorithm)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		outputContainsBinary(containsBinaryRows(lookupFileIndex(index, digest, fileSize)), i.StoragePath)
+		return
+	}
+	paths := sc.walkOverlay2(func(subPath string, info os.FileInfo) bool {
+		return info.Mode().IsRegular() && (fileSize < 0 || info.Size() == fileSize)
+	})
 
+	// 固定数量的协程计算摘要
+	pathsChan := make(chan string, len(paths))
+	for _, path := range paths {
+		pathsChan <- path
+	}
+	close(pathsChan)
+	matched := make([]string, 0)
 	var wg sync.WaitGroup
 	var m sync.Mutex
-	for _, path := range paths {
-		wg.Add(1)
-		go func(path string) {
-			defer func() {
-				wg.Done()
-			}()
-			body, err := os.ReadFile(path)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-			if fmt.Sprintf("%x", md5.Sum(body)) != filemd5 {
-				return
-			}
-			pathSplit := strings.Split(path, "/")
-			if len(pathSplit) <= 7 {
-				return
-			}
-			for _, info := range GetAllImagesInstance().ImageInfoFromLayerId(pathSplit[5]) {
-				containsBinaryDatas = append(containsBinaryDatas, &ContainsBinaryData{
-					ImageNameData: ImageNameData{
-						ImageName: info.ImageName,
-						ImageTag:  info.ImageTag,
-					},
-					ImageID:  strings.ReplaceAll(info.ImageID, "sha256:", "")[:12],
-					FilePath: path,
-				})
-				m.Lock()
-				if len(info.ImageName) > repoSize {
-					repoSize = len(info.ImageName)
+	wg.Add(sc.workers)
+	for n := 0; n < sc.workers; n++ {
+		go func() {
+			defer wg.Done()
+			for path := range pathsChan {
+				sum, err := util.FileHashLimit(path, algorithm, sc.limiter)
+				if err != nil {
+					log.Println(err)
+					continue
 				}
-				if len(info.ImageTag) > tagSize {
-					tagSize = len(info.ImageTag)
+				if info, err := os.Stat(path); err == nil {
+					sc.progress.AddFile(info.Size())
 				}
+				if sum != digest {
+					continue
+				}
+				m.Lock()
+				matched = append(matched, path)
 				m.Unlock()
 			}
-		}(path)
+		}()
 	}
 	wg.Wait()
-	outputContainsBinary(containsBinaryDatas, strconv.Itoa(repoSize), strconv.Itoa(tagSize))
+	outputContainsBinary(containsBinaryRows(matched), i.StoragePath)
 }
 
 func (i ImageRelation) ContainsImageLayerID() {
@@ -305,6 +407,37 @@ func (i ImageRelation) ContainsImageLayerID() {
 		}
 	}
 	outputContainsImage(containsImagesData, strconv.Itoa(repoSize), strconv.Itoa(tagSize))
+
+	// 使用该镜像层的容器
+	imageIds := make(map[string]bool)
+	for _, image := range imageList {
+		imageIds[image.ImageID] = true
+	}
+	containersInfo, err := getAllContainersInfo()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	containsContainersData := []*ContainsContainerData{}
+	nameSize := len("NAMES")
+	for _, container := range containersInfo {
+		if !imageIds[container.ImageID] && container.MountID != layerid {
+			continue
+		}
+		containsContainersData = append(containsContainersData, &ContainsContainerData{
+			ContainerID: container.ContainerID[:12],
+			Name:        container.Name,
+			ImageID:     strings.ReplaceAll(container.ImageID, "sha256:", "")[:12],
+			State:       container.State,
+		})
+		if len(container.Name) > nameSize {
+			nameSize = len(container.Name)
+		}
+	}
+	if len(containsContainersData) == 0 {
+		return
+	}
+	fmt.Println()
+	outputContainsContainer(containsContainersData, strconv.Itoa(nameSize))
 }
 
 func (i ImageRelation) ImageLayerContent() {
@@ -315,7 +448,7 @@ func (i ImageRelation) ImageLayerContent() {
 	imageLayerContentData := []*ImageLayerContentData{}
 	c_size := 0
 	for _, id := range image.ImageLayerIDS {
-		entries, err := ioutil.ReadDir("/var/lib/docker/overlay2/" + id.CacheID + "/diff")
+		entries, err := ioutil.ReadDir(overlay2Path + id.CacheID + "/diff")
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -326,7 +459,7 @@ func (i ImageRelation) ImageLayerContent() {
 		if len(content) > c_size {
 			c_size = len(content)
 		}
-		size, _ := util.DirSize("/var/lib/docker/overlay2/" + id.CacheID + "/diff")
+		size, _ := util.DirSize(overlay2Path + id.CacheID + "/diff")
 		imageLayerContentData = append(imageLayerContentData, &ImageLayerContentData{
 			ImageLayerID: ImageLayerID{
 				DiffID:  id.DiffID[:12],
@@ -350,18 +483,51 @@ func outputImageStorageLocation(data []*HistoryImageStorageData, createdBySize s
 }
 
 func outputContainsNoneLayer(data []*ContainsNoneLayerData, repoSize, tagSize string) {
-	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %s\n", "1111", repoSize), "9999", tagSize)
-	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "ROOTFS LAYERS")
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-13s %-10s %-14s %s\n", "1111", repoSize), "9999", tagSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "ROOTFS LAYERS", "SIMILARITY", "CREATED", "REASON")
 	for _, v := range data {
-		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, strconv.Itoa(v.Layers))
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, strconv.Itoa(v.Layers),
+			strconv.Itoa(v.Similarity)+"%", util.CreatedSince(v.Created), v.Reason)
 	}
 }
 
-func outputContainsBinary(data []*ContainsBinaryData, repoSize, tagSize string) {
-	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %s\n", "1111", repoSize), "9999", tagSize)
-	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "FILE PATH")
+func outputContainsBinary(data []*ContainsBinaryData, storagePath bool) {
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	pathSize := len("PATH")
 	for _, v := range data {
-		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, v.FilePath)
+		if len(v.ImageName) > repoSize {
+			repoSize = len(v.ImageName)
+		}
+		if len(v.ImageTag) > tagSize {
+			tagSize = len(v.ImageTag)
+		}
+		if len(v.ImagePath) > pathSize {
+			pathSize = len(v.ImagePath)
+		}
+	}
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-5s %-8888s %s", "1111", strconv.Itoa(repoSize)), "9999", strconv.Itoa(tagSize))
+	format = strings.ReplaceAll(format, "8888", strconv.Itoa(pathSize))
+	if !storagePath {
+		format += "\n"
+		fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "LAYER", "PATH", "CREATED BY")
+		for _, v := range data {
+			fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, strconv.Itoa(v.Layer), v.ImagePath, v.CreatedBy)
+		}
+		return
+	}
+	// CREATED BY 长度不固定, 放在最后一列
+	storageSize := len("STORAGE PATH")
+	for _, v := range data {
+		if len(v.FilePath) > storageSize {
+			storageSize = len(v.FilePath)
+		}
+	}
+	format = strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-5s %-8888s %-7777s %s\n", "1111", strconv.Itoa(repoSize)), "9999", strconv.Itoa(tagSize))
+	format = strings.ReplaceAll(strings.ReplaceAll(format, "8888", strconv.Itoa(pathSize)), "7777", strconv.Itoa(storageSize))
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "LAYER", "PATH", "STORAGE PATH", "CREATED BY")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, strconv.Itoa(v.Layer), v.ImagePath, v.FilePath, v.CreatedBy)
 	}
 }
 
@@ -374,6 +540,14 @@ func outputContainsImage(data []*ContainsImageData, repoSize, tagSize string) {
 	}
 }
 
+func outputContainsContainer(data []*ContainsContainerData, nameSize string) {
+	format := strings.ReplaceAll("%-12s %-1111s %-12s %s\n", "1111", nameSize)
+	fmt.Printf(format, "CONTAINER ID", "NAMES", "IMAGE ID", "STATE")
+	for _, v := range data {
+		fmt.Printf(format, v.ContainerID, v.Name, v.ImageID, v.State)
+	}
+}
+
 func output(content []*ImageLayerContentData, size string) {
 	format := strings.ReplaceAll("%-14s %-14s %-14s %-34s %s\n", "34", size)
 	fmt.Printf(format, "DIFF ID", "CHAIN ID", "CACHE ID", "CONTENT", "SIZE")
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"crypto/rand"
+	"docker-image/util"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SPDX 2.3 JSON 文档
+type SpdxDocument struct {
+	SpdxVersion       string              `json:"spdxVersion"`
+	DataLicense       string              `json:"dataLicense"`
+	SPDXID            string              `json:"SPDXID"`
+	Name              string              `json:"name"`
+	DocumentNamespace string              `json:"documentNamespace"`
+	CreationInfo      SpdxCreationInfo    `json:"creationInfo"`
+	Packages          []*SpdxPackage      `json:"packages"`
+	Relationships     []*SpdxRelationship `json:"relationships"`
+}
+
+type SpdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type SpdxPackage struct {
+	SPDXID                string             `json:"SPDXID"`
+	Name                  string             `json:"name"`
+	VersionInfo           string             `json:"versionInfo,omitempty"`
+	DownloadLocation      string             `json:"downloadLocation"`
+	FilesAnalyzed         bool               `json:"filesAnalyzed"`
+	LicenseConcluded      string             `json:"licenseConcluded"`
+	LicenseDeclared       string             `json:"licenseDeclared"`
+	LicenseComments       string             `json:"licenseComments,omitempty"`
+	CopyrightText         string             `json:"copyrightText"`
+	SourceInfo            string             `json:"sourceInfo,omitempty"`
+	PrimaryPackagePurpose string             `json:"primaryPackagePurpose,omitempty"`
+	Checksums             []*SpdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []*SpdxExternalRef `json:"externalRefs,omitempty"`
+	Comment               string             `json:"comment,omitempty"`
+}
+
+type SpdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type SpdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type SpdxRelationship struct {
+	SpdxElementId      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+// CycloneDX 1.5 JSON 文档
+type CycloneDXDocument struct {
+	BomFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     CycloneDXMetadata     `json:"metadata"`
+	Components   []*CycloneDXComponent `json:"components"`
+}
+
+type CycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp"`
+	Tools     CycloneDXTools      `json:"tools"`
+	Component *CycloneDXComponent `json:"component"`
+}
+
+type CycloneDXTools struct {
+	Components []*CycloneDXComponent `json:"components"`
+}
+
+type CycloneDXComponent struct {
+	Type       string               `json:"type"`
+	BomRef     string               `json:"bom-ref,omitempty"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version,omitempty"`
+	Purl       string               `json:"purl,omitempty"`
+	Hashes     []*CycloneDXHash     `json:"hashes,omitempty"`
+	Licenses   []*CycloneDXLicense  `json:"licenses,omitempty"`
+	Properties []*CycloneDXProperty `json:"properties,omitempty"`
+}
+
+type CycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type CycloneDXLicense struct {
+	License *CycloneDXLicenseName `json:"license"`
+}
+
+type CycloneDXLicenseName struct {
+	Name string `json:"name"`
+}
+
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SPDXID 只能包含字母、数字、. 和 -
+var spdxIDInvalid = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+// 生成 UUID v4
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalln(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// imageOSRelease 镜像合并视图中 /etc/os-release 的 ID 及 VERSION_ID
+func imageOSRelease(image *ImageInfo) (string, string) {
+	for _, path := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		_, entries, err := mergedLookup(image, path, true)
+		if err != nil || !entries[0].Info.Mode().IsRegular() {
+			continue
+		}
+		release, err := util.ParseOSRelease(entries[0].HostPath)
+		if err != nil {
+			continue
+		}
+		return release["ID"], release["VERSION_ID"]
+	}
+	return "", ""
+}
+
+// imageReference 镜像名称:TAG, none 标记的镜像使用镜像ID
+func imageReference(image *ImageInfo) (string, string) {
+	if isNoneImage(image) {
+		return strings.ReplaceAll(image.ImageID, "sha256:", "")[:12], ""
+	}
+	return image.ImageName, image.ImageTag
+}
+
+// imagePackageURL 镜像的 purl, 版本为仓库中的 manifest 摘要(RepoDigests), 没有时返回空
+// 镜像仓库地址放在 repository_url 中, 如 pkg:docker/app@sha256%3A...?repository_url=localhost:5000
+func imagePackageURL(image *ImageInfo) string {
+	if len(image.RepoDigests) == 0 {
+		return ""
+	}
+	repoDigest := image.RepoDigests[0]
+	for _, v := range image.RepoDigests {
+		if strings.Split(v, "@")[0] == image.ImageName {
+			repoDigest = v
+			break
+		}
+	}
+	repo, digest, ok := strings.Cut(repoDigest, "@")
+	if !ok {
+		return ""
+	}
+	registry := ""
+	// 第一段包含 . 或 : 或为 localhost 时是仓库地址
+	if first, rest, ok := strings.Cut(repo, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		registry, repo = first, rest
+	}
+	purl := "pkg:docker/" + repo + "@" + strings.ReplaceAll(digest, ":", "%3A")
+	if registry != "" {
+		purl += "?repository_url=" + registry
+	}
+	return purl
+}
+
+func spdxDocument(image *ImageInfo, packagesData []*PackageData, distroID, distroVersion string) *SpdxDocument {
+	name, tag := imageReference(image)
+	imageDigest := strings.ReplaceAll(image.ImageID, "sha256:", "")
+	doc := &SpdxDocument{
+		SpdxVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              strings.TrimSuffix(name+":"+tag, ":"),
+		DocumentNamespace: "https://docker-image.local/spdx/" + imageDigest + "-" + newUUID(),
+		CreationInfo: SpdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: docker-image"},
+		},
+		Packages:      make([]*SpdxPackage, 0),
+		Relationships: make([]*SpdxRelationship, 0),
+	}
+	doc.Packages = append(doc.Packages, &SpdxPackage{
+		SPDXID:                "SPDXRef-Image",
+		Name:                  name,
+		VersionInfo:           tag,
+		DownloadLocation:      "NOASSERTION",
+		LicenseConcluded:      "NOASSERTION",
+		LicenseDeclared:       "NOASSERTION",
+		CopyrightText:         "NOASSERTION",
+		PrimaryPackagePurpose: "CONTAINER",
+		Checksums:             []*SpdxChecksum{{Algorithm: "SHA256", ChecksumValue: imageDigest}},
+	})
+	if purl := imagePackageURL(image); purl != "" {
+		doc.Packages[0].ExternalRefs = []*SpdxExternalRef{{
+			ReferenceCategory: "PACKAGE-MANAGER",
+			ReferenceType:     "purl",
+			ReferenceLocator:  purl,
+		}}
+	}
+	doc.Relationships = append(doc.Relationships, &SpdxRelationship{
+		SpdxElementId: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSpdxElement: "SPDXRef-Image",
+	})
+	// 镜像层作为来源, 校验值为 DiffID
+	createdBy := imageLayerCreatedBy(image)
+	for idx, layer := range image.ImageLayerIDS {
+		layerID := "SPDXRef-Layer-" + strconv.Itoa(idx)
+		doc.Packages = append(doc.Packages, &SpdxPackage{
+			SPDXID:           layerID,
+			Name:             "layer-" + strconv.Itoa(idx),
+			VersionInfo:      "sha256:" + layer.DiffID,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			Checksums:        []*SpdxChecksum{{Algorithm: "SHA256", ChecksumValue: layer.DiffID}},
+			Comment:          createdBy[idx],
+		})
+		doc.Relationships = append(doc.Relationships, &SpdxRelationship{
+			SpdxElementId: "SPDXRef-Image", RelationshipType: "CONTAINS", RelatedSpdxElement: layerID,
+		})
+	}
+	for n, pkg := range packagesData {
+		pkgID := "SPDXRef-Package-" + pkg.Type + "-" + spdxIDInvalid.ReplaceAllString(pkg.Name, "-") + "-" + strconv.Itoa(n)
+		spdxPackage := &SpdxPackage{
+			SPDXID:           pkgID,
+			Name:             pkg.Name,
+			VersionInfo:      pkg.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs: []*SpdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  pkg.PackageURL(distroID, distroVersion),
+			}},
+		}
+		// 包管理器中的许可证不一定是合法的 SPDX 表达式, 只作为说明
+		if pkg.License != "" {
+			spdxPackage.LicenseComments = pkg.License
+		}
+		if pkg.Source != "" && pkg.Source != pkg.Name {
+			spdxPackage.SourceInfo = "built package from: " + pkg.Source
+		}
+		doc.Packages = append(doc.Packages, spdxPackage)
+		doc.Relationships = append(doc.Relationships, &SpdxRelationship{
+			SpdxElementId: "SPDXRef-Layer-" + strconv.Itoa(pkg.Layer), RelationshipType: "CONTAINS", RelatedSpdxElement: pkgID,
+		})
+	}
+	return doc
+}
+
+func cycloneDXDocument(image *ImageInfo, packagesData []*PackageData, distroID, distroVersion string) *CycloneDXDocument {
+	name, tag := imageReference(image)
+	imageDigest := strings.ReplaceAll(image.ImageID, "sha256:", "")
+	imageComponent := &CycloneDXComponent{
+		Type:    "container",
+		BomRef:  "image",
+		Name:    name,
+		Version: tag,
+		Purl:    imagePackageURL(image),
+		Hashes:  []*CycloneDXHash{{Alg: "SHA-256", Content: imageDigest}},
+	}
+	// 镜像层作为来源, 记录在镜像的属性中
+	createdBy := imageLayerCreatedBy(image)
+	for idx, layer := range image.ImageLayerIDS {
+		imageComponent.Properties = append(imageComponent.Properties,
+			&CycloneDXProperty{Name: "docker-image:layer:" + strconv.Itoa(idx) + ":diff_id", Value: "sha256:" + layer.DiffID},
+			&CycloneDXProperty{Name: "docker-image:layer:" + strconv.Itoa(idx) + ":created_by", Value: createdBy[idx]},
+		)
+	}
+	doc := &CycloneDXDocument{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: CycloneDXMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     CycloneDXTools{Components: []*CycloneDXComponent{{Type: "application", Name: "docker-image"}}},
+			Component: imageComponent,
+		},
+		Components: make([]*CycloneDXComponent, 0),
+	}
+	for n, pkg := range packagesData {
+		purl := pkg.PackageURL(distroID, distroVersion)
+		component := &CycloneDXComponent{
+			Type:    "library",
+			BomRef:  purl + "#" + strconv.Itoa(n),
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			Purl:    purl,
+			Properties: []*CycloneDXProperty{
+				{Name: "docker-image:package:type", Value: pkg.Type},
+				{Name: "docker-image:layer:index", Value: strconv.Itoa(pkg.Layer)},
+				{Name: "docker-image:layer:diff_id", Value: "sha256:" + pkg.DiffID},
+			},
+		}
+		if pkg.License != "" {
+			component.Licenses = []*CycloneDXLicense{{License: &CycloneDXLicenseName{Name: pkg.License}}}
+		}
+		if pkg.Source != "" && pkg.Source != pkg.Name {
+			component.Properties = append(component.Properties, &CycloneDXProperty{Name: "docker-image:package:source", Value: pkg.Source})
+		}
+		doc.Components = append(doc.Components, component)
+	}
+	return doc
+}
+
+// ImageSBOM 根据 overlay2 中的镜像层生成 SPDX 2.3 或 CycloneDX 1.5 JSON 格式的 SBOM
+func (i ImageRelation) ImageSBOM() {
+	image := mustImageInfo(i.ImageId)
+	packagesData := imagePackages(image)
+	distroID, distroVersion := imageOSRelease(image)
+	var doc interface{}
+	switch i.Format {
+	case "spdx", "spdx-json":
+		doc = spdxDocument(image, packagesData, distroID, distroVersion)
+	case "cyclonedx", "cyclonedx-json":
+		doc = cycloneDXDocument(image, packagesData, distroID, distroVersion)
+	default:
+		log.Fatalln("unsupported sbom format: " + i.Format)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		log.Fatalln(err)
+	}
+}
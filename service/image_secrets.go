@@ -0,0 +1,142 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type SecretData struct {
+	ImageNameData
+	ImageID   string `json:"image_id"`   // 镜像ID
+	Layer     int    `json:"layer"`      // 引入该文件的镜像层序号
+	DiffID    string `json:"diff_id"`    // 引入该文件的镜像层 DiffID
+	Rule      string `json:"rule"`       // 规则名称
+	Path      string `json:"path"`       // 镜像内路径
+	Line      int    `json:"line"`       // 行号
+	Secret    string `json:"secret"`     // 脱敏后的内容
+	Status    string `json:"status"`     // visible | removed | overwritten
+	CreatedBy string `json:"created_by"` // 创建该层的指令
+}
+
+// secretStatus 文件在镜像合并视图中的状态, 在上层删除或覆盖的文件仍然保存在镜像中
+func secretStatus(image *ImageInfo, path string, idx int) string {
+	entries := mergedLayers(image, path)
+	if len(entries) == 0 {
+		return "removed"
+	}
+	if entries[0].Layer != idx {
+		return "overwritten"
+	}
+	return "visible"
+}
+
+// SecretScan 检查所有镜像层中的私钥、云厂商凭证、token 等敏感信息, 包括在上层已删除的文件
+func (i ImageRelation) SecretScan() {
+	var mu sync.Mutex
+	fileFindings := make(map[string][]*util.SecretFinding)
+	sc := i.newScanner()
+	paths := sc.walkOverlay2(func(subPath string, info os.FileInfo) bool {
+		if !info.Mode().IsRegular() || info.Size() == 0 || (i.MaxFileSize > 0 && info.Size() > i.MaxFileSize) {
+			return false
+		}
+		findings, err := util.ScanSecrets(subPath, sc.limiter)
+		sc.progress.AddFile(info.Size())
+		// 读取出错前发现的结果仍然保留
+		if err != nil {
+			log.Println(subPath, err)
+		}
+		if len(findings) == 0 {
+			return false
+		}
+		mu.Lock()
+		fileFindings[subPath] = findings
+		mu.Unlock()
+		return true
+	})
+	sc.close()
+	secretsData := make([]*SecretData, 0)
+	createdBy := make(map[string][]string)
+	for _, path := range paths {
+		cacheID, imagePath := splitOverlay2Path(path)
+		for _, image := range GetAllImagesInstance().ImageInfoFromLayerId(cacheID) {
+			idx := imageLayerIndex(image, cacheID)
+			if idx < 0 {
+				continue
+			}
+			if _, ok := createdBy[image.ImageID]; !ok {
+				createdBy[image.ImageID] = imageLayerCreatedBy(image)
+			}
+			status := secretStatus(image, imagePath, idx)
+			for _, finding := range fileFindings[path] {
+				secretsData = append(secretsData, &SecretData{
+					ImageNameData: image.ImageNameData,
+					ImageID:       strings.ReplaceAll(image.ImageID, "sha256:", "")[:12],
+					Layer:         idx,
+					DiffID:        image.ImageLayerIDS[idx].DiffID[:12],
+					Rule:          finding.Rule,
+					Path:          imagePath,
+					Line:          finding.Line,
+					Secret:        finding.Secret,
+					Status:        status,
+					CreatedBy:     createdBy[image.ImageID][idx],
+				})
+			}
+		}
+	}
+	sort.SliceStable(secretsData, func(a, b int) bool {
+		x, y := secretsData[a], secretsData[b]
+		if x.ImageName+":"+x.ImageTag != y.ImageName+":"+y.ImageTag {
+			return x.ImageName+":"+x.ImageTag < y.ImageName+":"+y.ImageTag
+		}
+		if x.Layer != y.Layer {
+			return x.Layer < y.Layer
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.Line < y.Line
+	})
+	outputSecrets(secretsData)
+}
+
+func outputSecrets(data []*SecretData) {
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	ruleSize := len("RULE")
+	pathSize := len("PATH")
+	secretSize := len("SECRET")
+	for _, v := range data {
+		if len(v.ImageName) > repoSize {
+			repoSize = len(v.ImageName)
+		}
+		if len(v.ImageTag) > tagSize {
+			tagSize = len(v.ImageTag)
+		}
+		if len(v.Rule) > ruleSize {
+			ruleSize = len(v.Rule)
+		}
+		if len(v.Path) > pathSize {
+			pathSize = len(v.Path)
+		}
+		if len(v.Secret) > secretSize {
+			secretSize = len(v.Secret)
+		}
+	}
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-5s %-12s %-8888s %-7777s %-6s %-6666s %-11s %s\n", "1111", strconv.Itoa(repoSize)), "9999", strconv.Itoa(tagSize))
+	format = strings.ReplaceAll(strings.ReplaceAll(format, "8888", strconv.Itoa(ruleSize)), "7777", strconv.Itoa(pathSize))
+	format = strings.ReplaceAll(format, "6666", strconv.Itoa(secretSize))
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "LAYER", "DIFF ID", "RULE", "PATH", "LINE", "SECRET", "STATUS", "CREATED BY")
+	for _, v := range data {
+		line := ""
+		if v.Line > 0 {
+			line = strconv.Itoa(v.Line)
+		}
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, strconv.Itoa(v.Layer), v.DiffID, v.Rule, v.Path, line, v.Secret, v.Status, v.CreatedBy)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ImageShareData struct {
+	Name       string          `json:"name"`        // 镜像:TAG
+	ImageID    string          `json:"image_id"`    // 镜像ID
+	Layers     int             `json:"layers"`      // 层数
+	Size       int64           `json:"size"`        // 镜像层总大小
+	UniqueSize int64           `json:"unique_size"` // 只被本镜像使用的层大小, 删除本镜像可释放
+	SharedSize int64           `json:"shared_size"` // 与其他镜像共享的层大小
+	ChainIDs   map[string]bool `json:"-"`
+}
+
+type ImageShareCell struct {
+	Layers int   `json:"layers"` // 相同层数
+	Size   int64 `json:"size"`   // 相同层大小
+}
+
+// layerRefCount 统计每个 ChainID 被多少个镜像引用
+// 与 plan-rm 一致, 本地构建的中间镜像(没有 TAG 且有子镜像)随子镜像一起删除, 除非被容器使用, 否则不计入引用
+func layerRefCount(groups []*ImageGroup, usedBy map[string][]string) map[string]int {
+	parents := make(map[string]bool)
+	for _, group := range groups {
+		if group.ParentID != "" {
+			parents[group.ParentID] = true
+		}
+	}
+	refs := make(map[string]int)
+	for _, group := range groups {
+		if isUntaggedImage(group) && parents[group.ImageID] && len(usedBy[group.ImageID]) == 0 {
+			continue
+		}
+		for _, layer := range group.ImageLayerIDS {
+			refs[layer.ChainID] += 1
+		}
+	}
+	return refs
+}
+
+func (i ImageRelation) LayerShareReport() {
+	allGroups := GetAllImagesInstance().ImageGroups()
+	usedBy, err := containersByImageId()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	refs := layerRefCount(allGroups, usedBy)
+	sizes := make(map[string]int64)
+	groups := make([]*ImageGroup, 0)
+	for _, group := range allGroups {
+		for _, layer := range group.ImageLayerIDS {
+			if _, ok := sizes[layer.ChainID]; !ok {
+				sizes[layer.ChainID] = layerSize(layer)
+			}
+		}
+		if matchImagePattern(group, i.Pattern) {
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(a, b int) bool {
+		return groups[a].Names[0] < groups[b].Names[0]
+	})
+
+	imageSharesData := make([]*ImageShareData, 0, len(groups))
+	nameSize := len("REPOSITORY:TAG")
+	for _, group := range groups {
+		data := &ImageShareData{
+			Name:     group.Names[0],
+			ImageID:  strings.ReplaceAll(group.ImageID, "sha256:", "")[:12],
+			Layers:   len(group.ImageLayerIDS),
+			ChainIDs: make(map[string]bool),
+		}
+		for _, layer := range group.ImageLayerIDS {
+			data.ChainIDs[layer.ChainID] = true
+			data.Size += sizes[layer.ChainID]
+			if refs[layer.ChainID] == 1 {
+				data.UniqueSize += sizes[layer.ChainID]
+			} else {
+				data.SharedSize += sizes[layer.ChainID]
+			}
+		}
+		imageSharesData = append(imageSharesData, data)
+		if len(data.Name) > nameSize {
+			nameSize = len(data.Name)
+		}
+	}
+	outputImageShare(imageSharesData, strconv.Itoa(nameSize))
+
+	// 两两镜像之间相同的层
+	matrix := make([][]*ImageShareCell, len(imageSharesData))
+	for a, x := range imageSharesData {
+		matrix[a] = make([]*ImageShareCell, len(imageSharesData))
+		for b, y := range imageSharesData {
+			cell := &ImageShareCell{}
+			for chainID := range x.ChainIDs {
+				if y.ChainIDs[chainID] {
+					cell.Layers += 1
+					cell.Size += sizes[chainID]
+				}
+			}
+			matrix[a][b] = cell
+		}
+	}
+	fmt.Fprintln(os.Stdout)
+	outputImageShareMatrix(imageSharesData, matrix)
+
+	var total, dedup int64
+	chainIDs := make(map[string]bool)
+	for _, data := range imageSharesData {
+		total += data.Size
+		for chainID := range data.ChainIDs {
+			if !chainIDs[chainID] {
+				chainIDs[chainID] = true
+				dedup += sizes[chainID]
+			}
+		}
+	}
+	fmt.Fprintf(os.Stdout, "\ntotal image size: %s, actual disk usage: %s, saved by sharing: %s\n",
+		util.ImageSize(total), util.ImageSize(dedup), util.ImageSize(total-dedup))
+}
+
+func outputImageShare(data []*ImageShareData, nameSize string) {
+	format := strings.ReplaceAll("%-4s %-1111s %-12s %-6s %-10s %-10s %s\n", "1111", nameSize)
+	fmt.Fprintf(os.Stdout, format, "#", "REPOSITORY:TAG", "IMAGE ID", "LAYERS", "SIZE", "UNIQUE", "SHARED")
+	for n, v := range data {
+		fmt.Fprintf(os.Stdout, format, strconv.Itoa(n+1), v.Name, v.ImageID, strconv.Itoa(v.Layers),
+			util.ImageSize(v.Size), util.ImageSize(v.UniqueSize), util.ImageSize(v.SharedSize))
+	}
+}
+
+// 矩阵单元格: 相同层数/相同层大小
+func outputImageShareMatrix(data []*ImageShareData, matrix [][]*ImageShareCell) {
+	cellSize := 4
+	cells := make([][]string, len(matrix))
+	for a := range matrix {
+		cells[a] = make([]string, len(matrix[a]))
+		for b, cell := range matrix[a] {
+			cells[a][b] = "-"
+			if cell.Layers > 0 {
+				cells[a][b] = strconv.Itoa(cell.Layers) + "/" + util.ImageSize(cell.Size)
+			}
+			if len(cells[a][b]) > cellSize {
+				cellSize = len(cells[a][b])
+			}
+		}
+	}
+	format := "%-" + strconv.Itoa(cellSize) + "s "
+	fmt.Fprintf(os.Stdout, "%-4s ", "#")
+	for n := range data {
+		fmt.Fprintf(os.Stdout, format, strconv.Itoa(n+1))
+	}
+	fmt.Fprintln(os.Stdout)
+	for a := range cells {
+		fmt.Fprintf(os.Stdout, "%-4s ", strconv.Itoa(a+1))
+		for b := range cells[a] {
+			fmt.Fprintf(os.Stdout, format, cells[a][b])
+		}
+		fmt.Fprintln(os.Stdout)
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLayerRefCount(t *testing.T) {
+	layer := func(chainID string) *ImageLayerID {
+		return &ImageLayerID{ChainID: chainID}
+	}
+	group := func(id, parentID, name string, layers ...*ImageLayerID) *ImageGroup {
+		return &ImageGroup{
+			ImageInfo: &ImageInfo{ImageID: id, ParentID: parentID, ImageLayerIDS: layers},
+			Names:     []string{name},
+		}
+	}
+	// build 为本地构建 app 时的中间镜像, dangling 为重新构建后失去 TAG 的镜像
+	groups := []*ImageGroup{
+		group("base", "", "base:1.0", layer("a")),
+		group("build", "base", "<none>:<none>", layer("a"), layer("b")),
+		group("app", "build", "app:1.0", layer("a"), layer("b"), layer("c")),
+		group("dangling", "", "<none>:<none>", layer("a"), layer("d")),
+	}
+	tests := []struct {
+		name   string
+		usedBy map[string][]string
+		want   map[string]int
+	}{
+		{
+			name: "intermediate image",
+			want: map[string]int{"a": 3, "b": 1, "c": 1, "d": 1},
+		},
+		{
+			name:   "intermediate image used by container",
+			usedBy: map[string][]string{"build": {"debug"}},
+			want:   map[string]int{"a": 4, "b": 2, "c": 1, "d": 1},
+		},
+	}
+	for _, tt := range tests {
+		if got := layerRefCount(groups, tt.usedBy); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: layerRefCount() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
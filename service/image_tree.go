@@ -0,0 +1,127 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ImageTreeNode struct {
+	*ImageGroup
+	Parent       *ImageTreeNode   `json:"-"`
+	Children     []*ImageTreeNode `json:"children"`
+	SharedLayers int              `json:"shared_layers"` // 与父镜像相同的层数
+	SharedSize   int64            `json:"shared_size"`   // 与父镜像相同层的大小
+}
+
+// 镜像层总大小
+func layersSize(layers []*ImageLayerID) int64 {
+	var size int64
+	for _, layer := range layers {
+		size += layerSize(layer)
+	}
+	return size
+}
+
+// imageTree 为每个镜像找出 ChainID 前缀最长的镜像作为父镜像
+func imageTree() []*ImageTreeNode {
+	nodes := make([]*ImageTreeNode, 0)
+	for _, group := range GetAllImagesInstance().ImageGroups() {
+		nodes = append(nodes, &ImageTreeNode{ImageGroup: group})
+	}
+	sort.Slice(nodes, func(a, b int) bool {
+		return nodes[a].Names[0] < nodes[b].Names[0]
+	})
+	roots := make([]*ImageTreeNode, 0)
+	for _, node := range nodes {
+		layers := node.ImageLayerIDS
+		for _, base := range nodes {
+			baseLayers := base.ImageLayerIDS
+			if base == node || len(baseLayers) == 0 || len(baseLayers) > len(layers) || len(baseLayers) < node.SharedLayers {
+				continue
+			}
+			// ChainID 由下层所有 DiffID 计算得到, 相同即表示前缀相同
+			if baseLayers[len(baseLayers)-1].ChainID != layers[len(baseLayers)-1].ChainID {
+				continue
+			}
+			if len(baseLayers) == len(layers) {
+				// 层完全相同(仅元数据不同)时, 只能以更早创建的镜像作为父镜像, 避免成环
+				// 优先本地构建记录的 ParentID, 否则取其中最晚创建的镜像
+				if !imageCreatedBefore(base, node) {
+					continue
+				}
+				if node.Parent != nil && len(node.Parent.ImageLayerIDS) == len(layers) {
+					if node.Parent.ImageID == node.ParentID {
+						continue
+					}
+					if base.ImageID != node.ParentID && imageCreatedBefore(base, node.Parent) {
+						continue
+					}
+				}
+			} else if len(baseLayers) == node.SharedLayers {
+				continue
+			}
+			node.Parent = base
+			node.SharedLayers = len(baseLayers)
+		}
+		if node.Parent == nil {
+			roots = append(roots, node)
+			continue
+		}
+		node.SharedSize = layersSize(layers[:node.SharedLayers])
+		node.Parent.Children = append(node.Parent.Children, node)
+	}
+	return roots
+}
+
+// 按创建时间排序, 时间相同时按镜像ID排序
+func imageCreatedBefore(a, b *ImageTreeNode) bool {
+	if a.Created != b.Created {
+		return a.Created < b.Created
+	}
+	return a.ImageID < b.ImageID
+}
+
+func (i ImageRelation) ImageTree() {
+	roots := imageTree()
+	if i.ImageId != "" {
+		image := mustImageInfo(i.ImageId)
+		for _, root := range roots {
+			if findImageTreeNode(root, image.ImageID) != nil {
+				roots = []*ImageTreeNode{root}
+				break
+			}
+		}
+	}
+	for _, root := range roots {
+		fmt.Fprintf(os.Stdout, "%s (%s, %d layers, %s)\n", strings.Join(root.Names, ", "),
+			strings.ReplaceAll(root.ImageID, "sha256:", "")[:12], len(root.ImageLayerIDS), util.ImageSize(layersSize(root.ImageLayerIDS)))
+		outputImageTree(root.Children, "")
+	}
+}
+
+func findImageTreeNode(node *ImageTreeNode, imageId string) *ImageTreeNode {
+	if node.ImageID == imageId {
+		return node
+	}
+	for _, child := range node.Children {
+		if n := findImageTreeNode(child, imageId); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func outputImageTree(nodes []*ImageTreeNode, prefix string) {
+	for n, node := range nodes {
+		branch, indent := "├── ", "│   "
+		if n == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(os.Stdout, "%s%s%s (%s, %d layers) [shared %d layers, %s]\n", prefix, branch, strings.Join(node.Names, ", "),
+			strings.ReplaceAll(node.ImageID, "sha256:", "")[:12], len(node.ImageLayerIDS), node.SharedLayers, util.ImageSize(node.SharedSize))
+		outputImageTree(node.Children, prefix+indent)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type VerifyLayerData struct {
+	ImageLayerID
+	Status string `json:"status"` // 校验结果 ok | mismatch | 错误信息
+}
+
+type VerifyImageData struct {
+	ImageNameData
+	ImageID string `json:"image_id"` // 镜像ID
+	DiffID  string `json:"diff_id"`  // 校验失败的层
+	Status  string `json:"status"`   // 校验结果
+}
+
+// 校验镜像层: 根据 tar-split 元数据重新组装 diff 目录, 计算 sha256 与 DiffID 比较
+func verifyLayer(layer *ImageLayerID) string {
+	tarSplitPath := filepath.Join(layerdbPath, "sha256", layer.ChainID, "tar-split.json.gz")
+	diffPath := filepath.Join(overlay2Path, layer.CacheID, "diff")
+	digest, err := util.TarSplitDigest(tarSplitPath, diffPath)
+	if err != nil {
+		return err.Error()
+	}
+	if digest != layer.DiffID {
+		return "mismatch"
+	}
+	return "ok"
+}
+
+func (i ImageRelation) VerifyImageLayer() {
+	images := GetAllImagesInstance().Load()
+	if i.ImageId != "" {
+		images = []*ImageInfo{mustImageInfo(i.ImageId)}
+	}
+	// 相同 ChainID 的层只校验一次
+	layers := make(map[string]*ImageLayerID)
+	for _, image := range images {
+		for _, layer := range image.ImageLayerIDS {
+			layers[layer.ChainID] = layer
+		}
+	}
+	layersChan := make(chan *ImageLayerID, len(layers))
+	for _, layer := range layers {
+		layersChan <- layer
+	}
+	close(layersChan)
+	status := make(map[string]string, len(layers))
+	var w sync.WaitGroup
+	var m sync.Mutex
+	w.Add(10)
+	for n := 0; n < 10; n++ {
+		go func() {
+			defer w.Done()
+			for layer := range layersChan {
+				s := verifyLayer(layer)
+				m.Lock()
+				status[layer.ChainID] = s
+				m.Unlock()
+			}
+		}()
+	}
+	w.Wait()
+
+	verifyLayersData := make([]*VerifyLayerData, 0, len(layers))
+	for chainID, layer := range layers {
+		verifyLayersData = append(verifyLayersData, &VerifyLayerData{
+			ImageLayerID: ImageLayerID{
+				DiffID:  layer.DiffID[:12],
+				ChainID: layer.ChainID[:12],
+				CacheID: layer.CacheID[:12],
+			},
+			Status: status[chainID],
+		})
+	}
+	sort.Slice(verifyLayersData, func(a, b int) bool {
+		return verifyLayersData[a].ChainID < verifyLayersData[b].ChainID
+	})
+	outputVerifyLayer(verifyLayersData)
+
+	// 受影响的镜像
+	verifyImagesData := make([]*VerifyImageData, 0)
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	for _, image := range images {
+		for _, layer := range image.ImageLayerIDS {
+			if status[layer.ChainID] == "ok" {
+				continue
+			}
+			verifyImagesData = append(verifyImagesData, &VerifyImageData{
+				ImageNameData: ImageNameData{
+					ImageName: image.ImageName,
+					ImageTag:  image.ImageTag,
+				},
+				ImageID: strings.ReplaceAll(image.ImageID, "sha256:", "")[:12],
+				DiffID:  layer.DiffID[:12],
+				Status:  status[layer.ChainID],
+			})
+			if len(image.ImageName) > repoSize {
+				repoSize = len(image.ImageName)
+			}
+			if len(image.ImageTag) > tagSize {
+				tagSize = len(image.ImageTag)
+			}
+		}
+	}
+	if len(verifyImagesData) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stdout)
+	outputVerifyImage(verifyImagesData, strconv.Itoa(repoSize), strconv.Itoa(tagSize))
+	os.Exit(1)
+}
+
+func outputVerifyLayer(data []*VerifyLayerData) {
+	format := "%-14s %-14s %-14s %s\n"
+	fmt.Fprintf(os.Stdout, format, "DIFF ID", "CHAIN ID", "CACHE ID", "STATUS")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.DiffID, v.ChainID, v.CacheID, v.Status)
+	}
+}
+
+func outputVerifyImage(data []*VerifyImageData, repoSize, tagSize string) {
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-14s %s\n", "1111", repoSize), "9999", tagSize)
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "DIFF ID", "STATUS")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, v.DiffID, v.Status)
+	}
+}
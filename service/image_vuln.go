@@ -0,0 +1,204 @@
+package service
+
+import (
+	"docker-image/util"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type VulnData struct {
+	ImageNameData
+	ImageID string `json:"image_id"` // 镜像ID
+	VulnID  string `json:"vuln_id"`  // 漏洞ID
+	Aliases string `json:"aliases"`  // CVE 等别名
+	Type    string `json:"type"`     // 包类型
+	Package string `json:"package"`  // 包名称
+	Version string `json:"version"`  // 安装版本
+	Fixed   string `json:"fixed"`    // 修复版本
+	Layer   int    `json:"layer"`    // 安装该包的镜像层序号
+	DiffID  string `json:"diff_id"`  // 安装该包的镜像层 DiffID
+}
+
+type VulnLayerData struct {
+	ChainID   string `json:"chain_id"`   // 镜像层 ChainID
+	DiffID    string `json:"diff_id"`    // 镜像层 DiffID
+	Vulns     int    `json:"vulns"`      // 该层引入的漏洞数
+	Images    int    `json:"images"`     // 共享该层的镜像数
+	Base      string `json:"base"`       // 层数最少的共享镜像, 即需要修复的基础镜像
+	CreatedBy string `json:"created_by"` // 创建该层的指令
+}
+
+// baseImageName 共享该镜像层的带 TAG 镜像中层数最少的镜像
+func baseImageName(images []*ImageInfo) string {
+	var base *ImageInfo
+	for _, image := range images {
+		if isNoneImage(image) {
+			continue
+		}
+		if base == nil || len(image.ImageLayerIDS) < len(base.ImageLayerIDS) ||
+			(len(image.ImageLayerIDS) == len(base.ImageLayerIDS) && image.ImageName+":"+image.ImageTag < base.ImageName+":"+base.ImageTag) {
+			base = image
+		}
+	}
+	if base == nil {
+		return ""
+	}
+	return base.ImageName + ":" + base.ImageTag
+}
+
+// ImageVulnerability 根据本地 OSV 漏洞库匹配镜像中的包, 按镜像及镜像层显示漏洞
+// 未指定镜像时检查所有镜像, 多个镜像共享的镜像层只需在基础镜像中修复一次
+func (i ImageRelation) ImageVulnerability() {
+	db, err := util.LoadOSVDatabase(i.AdvisoryDB)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	groups := GetAllImagesInstance().ImageGroups()
+	if i.ImageId != "" {
+		// 包含该镜像ID的所有 TAG
+		image := mustImageInfo(i.ImageId)
+		for _, group := range groups {
+			if group.ImageID == image.ImageID {
+				groups = []*ImageGroup{group}
+				break
+			}
+		}
+	}
+	vulnsData := make([]*VulnData, 0)
+	layersData := make(map[string]*VulnLayerData)
+	// 同一镜像层的漏洞只统计一次
+	layerVulns := make(map[string]bool)
+	for _, group := range groups {
+		createdBy := imageLayerCreatedBy(group.ImageInfo)
+		distroID, distroVersion := imageOSRelease(group.ImageInfo)
+		for _, pkg := range imagePackages(group.ImageInfo) {
+			matches := db.Match(pkg.Package, distroID, distroVersion)
+			if len(matches) == 0 {
+				continue
+			}
+			layer := group.ImageLayerIDS[pkg.Layer]
+			if _, ok := layersData[layer.ChainID]; !ok {
+				shared := GetAllImagesInstance().ImageInfoFromLayerId(layer.ChainID)
+				imageIds := make(map[string]bool)
+				for _, image := range shared {
+					imageIds[image.ImageID] = true
+				}
+				layersData[layer.ChainID] = &VulnLayerData{
+					ChainID:   layer.ChainID,
+					DiffID:    layer.DiffID,
+					Images:    len(imageIds),
+					Base:      baseImageName(shared),
+					CreatedBy: createdBy[pkg.Layer],
+				}
+			}
+			for _, match := range matches {
+				for _, name := range group.Names {
+					// 仓库地址中可能带端口, 如 localhost:5000/app:1.0
+					sep := strings.LastIndex(name, ":")
+					vulnsData = append(vulnsData, &VulnData{
+						ImageNameData: ImageNameData{ImageName: name[:sep], ImageTag: name[sep+1:]},
+						ImageID:       strings.ReplaceAll(group.ImageID, "sha256:", "")[:12],
+						VulnID:        match.ID,
+						Aliases:       match.Aliases,
+						Type:          pkg.Type,
+						Package:       pkg.Name,
+						Version:       pkg.Version,
+						Fixed:         match.Fixed,
+						Layer:         pkg.Layer,
+						DiffID:        layer.DiffID[:12],
+					})
+				}
+				key := layer.ChainID + "/" + match.ID + "/" + pkg.Name
+				if !layerVulns[key] {
+					layerVulns[key] = true
+					layersData[layer.ChainID].Vulns += 1
+				}
+			}
+		}
+	}
+	sort.SliceStable(vulnsData, func(a, b int) bool {
+		x, y := vulnsData[a], vulnsData[b]
+		if x.ImageName+":"+x.ImageTag != y.ImageName+":"+y.ImageTag {
+			return x.ImageName+":"+x.ImageTag < y.ImageName+":"+y.ImageTag
+		}
+		if x.Layer != y.Layer {
+			return x.Layer < y.Layer
+		}
+		if x.Package != y.Package {
+			return x.Package < y.Package
+		}
+		return x.VulnID < y.VulnID
+	})
+	outputVulns(vulnsData)
+	vulnLayersData := make([]*VulnLayerData, 0, len(layersData))
+	for _, v := range layersData {
+		vulnLayersData = append(vulnLayersData, v)
+	}
+	// 共享镜像多、漏洞多的层优先修复
+	sort.SliceStable(vulnLayersData, func(a, b int) bool {
+		x, y := vulnLayersData[a], vulnLayersData[b]
+		if x.Images*x.Vulns != y.Images*y.Vulns {
+			return x.Images*x.Vulns > y.Images*y.Vulns
+		}
+		return x.DiffID < y.DiffID
+	})
+	if len(vulnLayersData) == 0 {
+		return
+	}
+	fmt.Println()
+	outputVulnLayers(vulnLayersData)
+}
+
+func outputVulns(data []*VulnData) {
+	repoSize := len("REPOSITORY")
+	tagSize := len("TAG")
+	idSize := len("VULN ID")
+	pkgSize := len("PACKAGE")
+	versionSize := len("VERSION")
+	for _, v := range data {
+		if len(v.ImageName) > repoSize {
+			repoSize = len(v.ImageName)
+		}
+		if len(v.ImageTag) > tagSize {
+			tagSize = len(v.ImageTag)
+		}
+		if len(v.VulnID) > idSize {
+			idSize = len(v.VulnID)
+		}
+		if len(v.Package) > pkgSize {
+			pkgSize = len(v.Package)
+		}
+		if len(v.Version) > versionSize {
+			versionSize = len(v.Version)
+		}
+		if len(v.Fixed) > versionSize {
+			versionSize = len(v.Fixed)
+		}
+	}
+	format := strings.ReplaceAll(strings.ReplaceAll("%-1111s %-9999s %-12s %-8888s %-4s %-7777s %-6666s %-6666s %-5s %-12s %s\n", "1111", strconv.Itoa(repoSize)), "9999", strconv.Itoa(tagSize))
+	format = strings.ReplaceAll(strings.ReplaceAll(format, "8888", strconv.Itoa(idSize)), "7777", strconv.Itoa(pkgSize))
+	format = strings.ReplaceAll(format, "6666", strconv.Itoa(versionSize))
+	fmt.Fprintf(os.Stdout, format, "REPOSITORY", "TAG", "IMAGE ID", "VULN ID", "TYPE", "PACKAGE", "VERSION", "FIXED", "LAYER", "DIFF ID", "ALIASES")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.ImageName, v.ImageTag, v.ImageID, v.VulnID, v.Type, v.Package, v.Version, v.Fixed,
+			strconv.Itoa(v.Layer), v.DiffID, v.Aliases)
+	}
+}
+
+func outputVulnLayers(data []*VulnLayerData) {
+	baseSize := len("BASE IMAGE")
+	for _, v := range data {
+		if len(v.Base) > baseSize {
+			baseSize = len(v.Base)
+		}
+	}
+	format := strings.ReplaceAll("%-12s %-5s %-6s %-1111s %s\n", "1111", strconv.Itoa(baseSize))
+	fmt.Fprintf(os.Stdout, format, "DIFF ID", "VULNS", "IMAGES", "BASE IMAGE", "CREATED BY")
+	for _, v := range data {
+		fmt.Fprintf(os.Stdout, format, v.DiffID[:12], strconv.Itoa(v.Vulns), strconv.Itoa(v.Images), v.Base, v.CreatedBy)
+	}
+}
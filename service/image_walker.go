@@ -0,0 +1,105 @@
+package service
+
+import (
+	"docker-image/util"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认并发数
+const defaultScanWorkers = 10
+
+// overlay2Scanner 文件扫描的并发数、读取速率限制及进度输出
+type overlay2Scanner struct {
+	workers  int
+	limiter  *util.RateLimiter
+	progress *util.Progress
+}
+
+// newScanner 根据参数创建扫描器, 使用完需要调用 close
+func (i ImageRelation) newScanner() *overlay2Scanner {
+	if i.LowPriority {
+		if err := util.SetLowPriority(); err != nil {
+			log.Println(err)
+		}
+	}
+	sc := &overlay2Scanner{workers: i.Workers, limiter: util.NewRateLimiter(i.IOLimit)}
+	if sc.workers <= 0 {
+		sc.workers = defaultScanWorkers
+	}
+	if i.Progress {
+		sc.progress = util.NewProgress(time.Second)
+	}
+	return sc
+}
+
+func (sc *overlay2Scanner) close() {
+	sc.progress.Stop()
+}
+
+// walkOverlay2 并发遍历所有 overlay2/<id>/diff 目录, 返回 match 匹配的路径
+func (sc *overlay2Scanner) walkOverlay2(match func(subPath string, info os.FileInfo) bool) []string {
+	var paths []string
+	dirsEntry, _ := os.ReadDir(overlay2Path)
+	dirsNameChan := make(chan string, len(dirsEntry))
+	for _, fd := range dirsEntry {
+		if fd.IsDir() && fd.Name() != "l" {
+			dirsNameChan <- fd.Name()
+		}
+	}
+	close(dirsNameChan)
+	sc.progress.AddLayers(len(dirsNameChan))
+	tmppathsChan := make(chan string, len(dirsEntry))
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for path := range tmppathsChan {
+			paths = append(paths, path)
+		}
+	}()
+	// 协程遍历处理
+	var w sync.WaitGroup
+	w.Add(sc.workers)
+	for i := 0; i < sc.workers; i++ {
+		go func() {
+			defer w.Done()
+			for path := range dirsNameChan {
+				diffPath := filepath.Join(overlay2Path, path, "diff")
+				err := filepath.Walk(diffPath, func(subPath string, info os.FileInfo, err error) error {
+					if err != nil {
+						if subPath == diffPath && os.IsNotExist(err) {
+							return nil
+						}
+						return err
+					}
+					if subPath != diffPath && match(subPath, info) {
+						tmppathsChan <- subPath
+					}
+					return nil
+				})
+				if err != nil {
+					log.Println(err)
+				}
+				sc.progress.LayerDone()
+			}
+		}()
+	}
+	w.Wait()
+	close(tmppathsChan)
+	<-collected
+	return paths
+}
+
+// 拆分 overlay2 路径为 cache-id 与镜像内路径
+func splitOverlay2Path(path string) (string, string) {
+	rel := strings.TrimPrefix(path, filepath.Clean(overlay2Path)+"/")
+	split := strings.SplitN(rel, "/", 3)
+	if len(split) < 3 || split[1] != "diff" {
+		return split[0], ""
+	}
+	return split[0], "/" + split[2]
+}
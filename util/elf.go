@@ -0,0 +1,94 @@
+package util
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+const ntGNUBuildID = 3
+
+// IsELF 文件开头是否为 ELF 魔数
+func IsELF(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == elf.ELFMAG
+}
+
+// ELFBuildID 读取 ELF 文件 NT_GNU_BUILD_ID, 没有时返回空字符串
+func ELFBuildID(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return "", err
+		}
+		if id := gnuBuildID(data, f.ByteOrder); id != "" {
+			return id, nil
+		}
+	}
+	for _, section := range f.Sections {
+		if section.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := section.Data()
+		if err != nil {
+			return "", err
+		}
+		if id := gnuBuildID(data, f.ByteOrder); id != "" {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// gnuBuildID 解析 note 段, 格式为 namesz descsz type name desc, name 与 desc 按 4 字节对齐
+func gnuBuildID(data []byte, order binary.ByteOrder) string {
+	for len(data) >= 12 {
+		namesz := int(order.Uint32(data[0:4]))
+		descsz := int(order.Uint32(data[4:8]))
+		noteType := order.Uint32(data[8:12])
+		nameEnd := 12 + align4(namesz)
+		descEnd := nameEnd + align4(descsz)
+		if namesz < 0 || descsz < 0 || descEnd > len(data) || nameEnd+descsz > len(data) {
+			return ""
+		}
+		name := bytes.TrimRight(data[12:12+namesz], "\x00")
+		if noteType == ntGNUBuildID && string(name) == "GNU" {
+			return hex.EncodeToString(data[nameEnd : nameEnd+descsz])
+		}
+		data = data[descEnd:]
+	}
+	return ""
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// GoBuildInfo 读取 Go 程序内嵌的模块及 VCS 信息, 非 Go 程序返回 nil
+func GoBuildInfo(path string) *buildinfo.BuildInfo {
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return info
+}
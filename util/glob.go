@@ -0,0 +1,44 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GlobToRegexp 路径通配符转换为正则表达式
+// * 匹配除 / 外任意字符, ? 匹配除 / 外单个字符, ** 匹配任意层目录
+func GlobToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for n := 0; n < len(pattern); n++ {
+		c := pattern[n]
+		switch {
+		case strings.HasPrefix(pattern[n:], "**/"):
+			b.WriteString("(.*/)?")
+			n += 2
+		case strings.HasPrefix(pattern[n:], "**"):
+			b.WriteString(".*")
+			n += 1
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[n:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := pattern[n+1 : n+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			n += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
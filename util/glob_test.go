@@ -0,0 +1,33 @@
+package util
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/etc/*.conf", "/etc/nginx.conf", true},
+		{"/etc/*.conf", "/etc/nginx/nginx.conf", false},
+		{"/etc/**/*.conf", "/etc/nginx.conf", true},
+		{"/etc/**/*.conf", "/etc/nginx/conf.d/default.conf", true},
+		{"/usr/**", "/usr/bin/env", true},
+		{"/usr/**", "/opt/bin/env", false},
+		{"/bin/?h", "/bin/sh", true},
+		{"/bin/?h", "/bin/bash", false},
+		{"/lib/libc.so.[0-9]", "/lib/libc.so.6", true},
+		{"/lib/libc.so.[!0-9]", "/lib/libc.so.6", false},
+		{"/tmp/a+b(1).txt", "/tmp/a+b(1).txt", true},
+		{"/tmp/[abc", "/tmp/[abc", true},
+	}
+	for _, tt := range tests {
+		re, err := GlobToRegexp(tt.pattern)
+		if err != nil {
+			t.Fatalf("GlobToRegexp(%q): %v", tt.pattern, err)
+		}
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("GlobToRegexp(%q).MatchString(%q) = %v, want %v", tt.pattern, tt.path, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+// 检测二进制文件时读取的字节数
+const binarySniffSize = 8000
+
+type GrepMatch struct {
+	Line int    // 行号
+	Text string // 匹配行内容
+}
+
+// 每行最多匹配的字节数, 超出部分跳过
+const grepLineSize = 1024 * 1024
+
+// GrepFile 按行匹配文件内容, 二进制文件(前 8000 字节含 NUL)直接跳过
+// 超长行只匹配前 1MB, 读取出错时返回已匹配的行
+func GrepFile(path string, re *regexp.Regexp, limiter *RateLimiter) ([]GrepMatch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// 缓冲区不小于检测长度, 否则 Peek 只能读到默认的 4096 字节
+	reader := bufio.NewReaderSize(limiter.Reader(f), binarySniffSize)
+	head, err := reader.Peek(binarySniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+	var matches []GrepMatch
+	buf := make([]byte, 0, 4096)
+	for n := 1; ; {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return matches, err
+		}
+		if len(buf) < grepLineSize {
+			if len(buf)+len(chunk) > grepLineSize {
+				chunk = chunk[:grepLineSize-len(buf)]
+			}
+			buf = append(buf, chunk...)
+		}
+		if isPrefix {
+			continue
+		}
+		if re.Match(buf) {
+			matches = append(matches, GrepMatch{Line: n, Text: string(buf)})
+		}
+		buf = buf[:0]
+		n++
+	}
+	return matches, nil
+}
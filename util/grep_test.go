@@ -0,0 +1,73 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGrepFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		content string
+		want    []GrepMatch
+	}{
+		{
+			name:    "match lines",
+			pattern: `listen\s+\d+`,
+			content: "server {\n    listen 80;\n    listen 443 ssl;\n}\n",
+			want:    []GrepMatch{{Line: 2, Text: "    listen 80;"}, {Line: 3, Text: "    listen 443 ssl;"}},
+		},
+		{
+			name:    "no trailing newline",
+			pattern: `^PATH=`,
+			content: "HOME=/root\nPATH=/usr/bin",
+			want:    []GrepMatch{{Line: 2, Text: "PATH=/usr/bin"}},
+		},
+		{
+			// 超长行不影响之后的行
+			name:    "long line",
+			pattern: `^PATH=`,
+			content: "PATH=" + strings.Repeat("a", 2*grepLineSize) + "\nPATH=/usr/bin\n",
+			want:    []GrepMatch{{Line: 1, Text: "PATH=" + strings.Repeat("a", grepLineSize-len("PATH="))}, {Line: 2, Text: "PATH=/usr/bin"}},
+		},
+		{
+			name:    "no match",
+			pattern: `password`,
+			content: "user=root\n",
+		},
+		{
+			name:    "binary file",
+			pattern: `ELF`,
+			content: "\x7fELF\x02\x01\x01\x00" + strings.Repeat("ELF\n", 10),
+		},
+		{
+			// 二进制检测需要读取完整的 8000 字节
+			name:    "nul after 4096 bytes",
+			pattern: `ELF`,
+			content: strings.Repeat("a", 5000) + "\x00\nELF\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := GrepFile(writeTestFile(t, tt.content), regexp.MustCompile(tt.pattern), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: GrepFile() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
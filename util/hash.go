@@ -0,0 +1,60 @@
+package util
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+// NewHash 文件内容摘要算法 sha256 | blake3 | xxhash | md5
+func NewHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "blake3":
+		return blake3.New(32, nil), nil
+	case "xxhash":
+		return xxhash.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, errors.New("unsupported hash algorithm: " + algorithm)
+}
+
+// FileHash 流式计算文件摘要, 不会将文件全部读入内存
+func FileHash(path, algorithm string) (string, error) {
+	return FileHashLimit(path, algorithm, nil)
+}
+
+// FileHashLimit 按速率限制读取文件计算摘要
+func FileHashLimit(path, algorithm string, limiter *RateLimiter) (string, error) {
+	h, err := NewHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, limiter.Reader(f)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ParseDigest 解析 "算法:摘要" 格式, 未指定算法时使用 defaultAlgorithm
+func ParseDigest(digest, defaultAlgorithm string) (string, string) {
+	if split := strings.SplitN(digest, ":", 2); len(split) == 2 {
+		return split[0], strings.ToLower(split[1])
+	}
+	return defaultAlgorithm, strings.ToLower(digest)
+}
@@ -0,0 +1,277 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OSV 格式漏洞信息, https://ossf.github.io/osv-schema/
+type OSVEntry struct {
+	ID       string         `json:"id"`
+	Aliases  []string       `json:"aliases"`
+	Summary  string         `json:"summary"`
+	Affected []*OSVAffected `json:"affected"`
+}
+
+type OSVAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string              `json:"type"`
+		Events []map[string]string `json:"events"`
+	} `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+type osvRef struct {
+	entry    *OSVEntry
+	affected *OSVAffected
+}
+
+// OSVDatabase 按 生态:包名称 索引的本地漏洞库
+type OSVDatabase struct {
+	packages map[string][]*osvRef
+}
+
+type OSVMatch struct {
+	ID      string // 漏洞ID
+	Aliases string // CVE 等别名
+	Summary string // 摘要
+	Fixed   string // 修复版本, 为空表示没有修复版本
+}
+
+// 发行版 ID 对应的 OSV 生态名称
+var osvEcosystems = map[string]string{
+	"alpine":              "Alpine",
+	"debian":              "Debian",
+	"ubuntu":              "Ubuntu",
+	"rhel":                "Red Hat",
+	"rocky":               "Rocky Linux",
+	"almalinux":           "AlmaLinux",
+	"opensuse-leap":       "openSUSE",
+	"opensuse-tumbleweed": "openSUSE",
+	"sles":                "SUSE",
+	"wolfi":               "Wolfi",
+	"chainguard":          "Chainguard",
+	"mariner":             "Mariner",
+	"azurelinux":          "Azure Linux",
+}
+
+// 未识别发行版时按包类型使用的生态名称
+var osvDefaultEcosystems = map[string]string{"apk": "Alpine", "deb": "Debian", "rpm": "Red Hat"}
+
+// OSVEcosystem 包对应的 OSV 生态名称(不含版本)
+func OSVEcosystem(pkgType, distroID string) string {
+	if ecosystem, ok := osvEcosystems[distroID]; ok {
+		return ecosystem
+	}
+	return osvDefaultEcosystems[pkgType]
+}
+
+// LoadOSVDatabase 加载 OSV 漏洞库, 支持 osv.dev 导出的 all.zip、JSON 文件目录、单个 JSON 文件(对象或数组)
+func LoadOSVDatabase(path string) (*OSVDatabase, error) {
+	db := &OSVDatabase{packages: make(map[string][]*osvRef)}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		err := filepath.Walk(path, func(subPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), ".json") {
+				b, err := os.ReadFile(subPath)
+				if err != nil {
+					return err
+				}
+				return db.add(b)
+			}
+			return nil
+		})
+		return db, err
+	}
+	if strings.HasSuffix(path, ".zip") {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if !strings.HasSuffix(f.Name, ".json") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			if err := db.add(b); err != nil {
+				return nil, err
+			}
+		}
+		return db, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return db, db.add(b)
+}
+
+func (db *OSVDatabase) add(b []byte) error {
+	entries := make([]*OSVEntry, 0)
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		if err := json.Unmarshal(b, &entries); err != nil {
+			return err
+		}
+	} else {
+		entry := &OSVEntry{}
+		if err := json.Unmarshal(b, entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	for _, entry := range entries {
+		for _, affected := range entry.Affected {
+			ecosystem := strings.SplitN(affected.Package.Ecosystem, ":", 2)[0]
+			key := ecosystem + ":" + affected.Package.Name
+			db.packages[key] = append(db.packages[key], &osvRef{entry: entry, affected: affected})
+		}
+	}
+	return nil
+}
+
+// Len 漏洞库中的包数量
+func (db *OSVDatabase) Len() int {
+	return len(db.packages)
+}
+
+var osvReleasePattern = regexp.MustCompile(`^v?[0-9]`)
+
+// matchEcosystemRelease 生态中的发行版本(如 Alpine:v3.18、Debian:12)与镜像发行版本是否一致
+func matchEcosystemRelease(ecosystem, distroVersion string) bool {
+	split := strings.Split(ecosystem, ":")
+	if len(split) < 2 || distroVersion == "" {
+		return true
+	}
+	release := split[1]
+	if split[0] == "Red Hat" {
+		release = redHatRelease(split[1:])
+	}
+	if !osvReleasePattern.MatchString(release) {
+		return true
+	}
+	release = strings.TrimPrefix(release, "v")
+	return distroVersion == release || strings.HasPrefix(distroVersion, release+".")
+}
+
+// redHatRelease Red Hat 生态为 CPE 风格的 产品:版本::仓库, 如 enterprise_linux:9::appstream、
+// rhel_eus:8.6::baseos, 其他产品(如 openshift:4.14::el9)从 elN 中取 RHEL 主版本
+func redHatRelease(cpe []string) string {
+	for _, field := range cpe[1:] {
+		if strings.HasPrefix(field, "el") && osvReleasePattern.MatchString(field[2:]) {
+			return field[2:]
+		}
+	}
+	if len(cpe) >= 2 && (strings.HasPrefix(cpe[0], "enterprise_linux") || strings.HasPrefix(cpe[0], "rhel")) {
+		return cpe[1]
+	}
+	return ""
+}
+
+// affectedRange 按 OSV 规则判断版本是否在受影响范围内, 返回是否受影响及修复版本
+func affectedRange(pkgType, version string, events []map[string]string) (bool, string) {
+	type event struct {
+		kind    string
+		version string
+	}
+	sorted := make([]event, 0, len(events))
+	for _, e := range events {
+		for kind, v := range e {
+			sorted = append(sorted, event{kind: kind, version: v})
+		}
+	}
+	sort.SliceStable(sorted, func(a, b int) bool {
+		if sorted[a].version == "0" || sorted[b].version == "0" {
+			return sorted[a].version == "0" && sorted[b].version != "0"
+		}
+		return CompareVersion(pkgType, sorted[a].version, sorted[b].version) < 0
+	})
+	affected, fixed := false, ""
+	for _, e := range sorted {
+		switch e.kind {
+		case "introduced":
+			if e.version == "0" || CompareVersion(pkgType, version, e.version) >= 0 {
+				affected = true
+			}
+		case "fixed":
+			if CompareVersion(pkgType, version, e.version) >= 0 {
+				affected = false
+			} else if affected && fixed == "" {
+				fixed = e.version
+			}
+		case "last_affected":
+			if CompareVersion(pkgType, version, e.version) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected, fixed
+}
+
+// Match 按包名称及源码包名称查找影响该包版本的漏洞
+func (db *OSVDatabase) Match(pkg *Package, distroID, distroVersion string) []*OSVMatch {
+	ecosystem := OSVEcosystem(pkg.Type, distroID)
+	matches := make([]*OSVMatch, 0)
+	seen := make(map[string]bool)
+	for _, name := range []string{pkg.Name, pkg.Source} {
+		if name == "" {
+			continue
+		}
+		for _, ref := range db.packages[ecosystem+":"+name] {
+			if seen[ref.entry.ID] || !matchEcosystemRelease(ref.affected.Package.Ecosystem, distroVersion) {
+				continue
+			}
+			affected, fixed := false, ""
+			for _, v := range ref.affected.Versions {
+				if v == pkg.Version {
+					affected = true
+				}
+			}
+			for _, r := range ref.affected.Ranges {
+				if affected || r.Type == "GIT" {
+					continue
+				}
+				affected, fixed = affectedRange(pkg.Type, pkg.Version, r.Events)
+			}
+			if !affected {
+				continue
+			}
+			seen[ref.entry.ID] = true
+			matches = append(matches, &OSVMatch{
+				ID:      ref.entry.ID,
+				Aliases: strings.Join(ref.entry.Aliases, ","),
+				Summary: ref.entry.Summary,
+				Fixed:   fixed,
+			})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].ID < matches[b].ID
+	})
+	return matches
+}
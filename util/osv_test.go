@@ -0,0 +1,88 @@
+package util
+
+import "testing"
+
+func TestAffectedRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkgType  string
+		version  string
+		events   []map[string]string
+		affected bool
+		fixed    string
+	}{
+		{
+			name:     "before fix",
+			pkgType:  "deb",
+			version:  "7.88.1-10",
+			events:   []map[string]string{{"introduced": "0"}, {"fixed": "7.88.1-10+deb12u5"}},
+			affected: true,
+			fixed:    "7.88.1-10+deb12u5",
+		},
+		{
+			name:    "fixed",
+			pkgType: "deb",
+			version: "7.88.1-10+deb12u5",
+			events:  []map[string]string{{"introduced": "0"}, {"fixed": "7.88.1-10+deb12u5"}},
+		},
+		{
+			name:    "before introduced",
+			pkgType: "apk",
+			version: "1.2.3-r0",
+			events:  []map[string]string{{"introduced": "1.2.4-r0"}, {"fixed": "1.2.5-r0"}},
+		},
+		{
+			name:     "last affected",
+			pkgType:  "rpm",
+			version:  "2.34-60.el9",
+			events:   []map[string]string{{"introduced": "0"}, {"last_affected": "2.34-60.el9"}},
+			affected: true,
+		},
+		{
+			name:    "after last affected",
+			pkgType: "rpm",
+			version: "2.34-100.el9",
+			events:  []map[string]string{{"introduced": "0"}, {"last_affected": "2.34-60.el9"}},
+		},
+		{
+			name:     "second range",
+			pkgType:  "apk",
+			version:  "2.1-r0",
+			events:   []map[string]string{{"introduced": "0"}, {"fixed": "1.5-r0"}, {"introduced": "2.0-r0"}, {"fixed": "2.2-r0"}},
+			affected: true,
+			fixed:    "2.2-r0",
+		},
+	}
+	for _, tt := range tests {
+		affected, fixed := affectedRange(tt.pkgType, tt.version, tt.events)
+		if affected != tt.affected || fixed != tt.fixed {
+			t.Errorf("%s: affectedRange() = %v, %q, want %v, %q", tt.name, affected, fixed, tt.affected, tt.fixed)
+		}
+	}
+}
+
+func TestMatchEcosystemRelease(t *testing.T) {
+	tests := []struct {
+		ecosystem     string
+		distroVersion string
+		want          bool
+	}{
+		{"Alpine:v3.18", "3.18.4", true},
+		{"Alpine:v3.18", "3.19.0", false},
+		{"Debian:12", "12", true},
+		{"Debian:11", "12", false},
+		{"Debian", "12", true},
+		{"Alpine:v3.18", "", true},
+		{"Red Hat:enterprise_linux:9::appstream", "9.3", true},
+		{"Red Hat:enterprise_linux:8::appstream", "9.3", false},
+		{"Red Hat:rhel_eus:8.6::baseos", "8.6", true},
+		{"Red Hat:rhel_eus:8.6::baseos", "8.9", false},
+		{"Red Hat:openshift:4.14::el9", "9.2", true},
+		{"Red Hat:openshift:4.14::el8", "9.2", false},
+	}
+	for _, tt := range tests {
+		if got := matchEcosystemRelease(tt.ecosystem, tt.distroVersion); got != tt.want {
+			t.Errorf("matchEcosystemRelease(%q, %q) = %v, want %v", tt.ecosystem, tt.distroVersion, got, tt.want)
+		}
+	}
+}
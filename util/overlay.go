@@ -0,0 +1,33 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// overlay2 whiteout 标记
+// ref: github.com/moby/moby/pkg/archive
+const (
+	WhiteoutPrefix    = ".wh."
+	WhiteoutOpaqueDir = ".wh..wh..opq"
+)
+
+// IsWhiteout 判断是否为 overlay2 删除标记(0/0 字符设备)
+func IsWhiteout(info os.FileInfo) bool {
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+// IsOpaqueDir 判断目录是否为 opaque 目录(屏蔽下层同名目录内容)
+func IsOpaqueDir(path string) bool {
+	dest := make([]byte, 1)
+	if n, err := syscall.Getxattr(path, "trusted.overlay.opaque", dest); err == nil && n == 1 && dest[0] == 'y' {
+		return true
+	}
+	_, err := os.Lstat(filepath.Join(path, WhiteoutOpaqueDir))
+	return err == nil
+}
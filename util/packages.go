@@ -0,0 +1,243 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "github.com/glebarez/go-sqlite" // rpm sqlite 数据库驱动
+	rpmdb "github.com/knqyf263/go-rpmdb/pkg"
+)
+
+type Package struct {
+	Type    string // apk | deb | rpm
+	Name    string // 包名称
+	Version string // 版本, 包含 epoch 及 release
+	Arch    string // 架构
+	Source  string // 源码包名称
+	License string // 许可证
+}
+
+// 各类型包数据库在镜像内的路径
+var (
+	ApkInstalledPath = "lib/apk/db/installed"
+	DpkgStatusPath   = "var/lib/dpkg/status"
+	DpkgStatusDir    = "var/lib/dpkg/status.d" // distroless 等镜像每个包一个文件
+	RpmDBPaths       = []string{
+		"var/lib/rpm/rpmdb.sqlite",
+		"var/lib/rpm/Packages.db",
+		"var/lib/rpm/Packages",
+		"usr/lib/sysimage/rpm/rpmdb.sqlite",
+		"usr/lib/sysimage/rpm/Packages.db",
+	}
+)
+
+// ParseApkInstalled 解析 /lib/apk/db/installed, 每个包一段, 段之间为空行
+func ParseApkInstalled(path string) ([]*Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	packages := make([]*Package, 0)
+	pkg := &Package{Type: "apk"}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if pkg.Name != "" {
+				packages = append(packages, pkg)
+			}
+			pkg = &Package{Type: "apk"}
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		value := line[2:]
+		switch line[0] {
+		case 'P':
+			pkg.Name = value
+		case 'V':
+			pkg.Version = value
+		case 'A':
+			pkg.Arch = value
+		case 'o':
+			pkg.Source = value
+		case 'L':
+			pkg.License = value
+		}
+	}
+	if pkg.Name != "" {
+		packages = append(packages, pkg)
+	}
+	return packages, scanner.Err()
+}
+
+// ParseDpkgStatus 解析 /var/lib/dpkg/status, 只返回已安装的包
+func ParseDpkgStatus(path string) ([]*Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	packages := make([]*Package, 0)
+	pkg := &Package{Type: "deb"}
+	installed := true
+	add := func() {
+		if pkg.Name != "" && installed {
+			if pkg.Source == "" {
+				pkg.Source = pkg.Name
+			}
+			packages = append(packages, pkg)
+		}
+		pkg = &Package{Type: "deb"}
+		installed = true
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			add()
+			continue
+		}
+		// 续行
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		split := strings.SplitN(line, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(split[1])
+		switch split[0] {
+		case "Package":
+			pkg.Name = value
+		case "Version":
+			pkg.Version = value
+		case "Architecture":
+			pkg.Arch = value
+		case "Source":
+			// Source: name (version)
+			if fields := strings.Fields(value); len(fields) > 0 {
+				pkg.Source = fields[0]
+			}
+		case "Status":
+			installed = strings.HasSuffix(value, " installed")
+		}
+	}
+	add()
+	return packages, scanner.Err()
+}
+
+// ParseRpmDB 解析 rpm 数据库, 支持 sqlite、Berkeley DB 及 ndb 格式
+// sqlite 打开时会在数据库所在目录创建 -wal、-shm 文件, 先复制到临时目录, 不写入镜像层
+func ParseRpmDB(path string) ([]*Package, error) {
+	dir, err := os.MkdirTemp("", "docker-image-rpmdb-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmpPath := filepath.Join(dir, filepath.Base(path))
+	if err := copyFile(path, tmpPath); err != nil {
+		return nil, err
+	}
+	// 未合并到数据库的 WAL 记录
+	if err := copyFile(path+"-wal", tmpPath+"-wal"); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	db, err := rpmdb.Open(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	infos, err := db.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]*Package, 0, len(infos))
+	for _, info := range infos {
+		version := info.Version + "-" + info.Release
+		if info.Epoch != nil && *info.Epoch != 0 {
+			version = strconv.Itoa(*info.Epoch) + ":" + version
+		}
+		source := info.SourceRpm
+		// 源码包名称, 去掉 -version-release.src.rpm
+		if n := strings.LastIndex(source, "-"); n > 0 {
+			if n = strings.LastIndex(source[:n], "-"); n > 0 {
+				source = source[:n]
+			}
+		}
+		packages = append(packages, &Package{
+			Type:    "rpm",
+			Name:    info.Name,
+			Version: version,
+			Arch:    info.Arch,
+			Source:  source,
+			License: info.License,
+		})
+	}
+	return packages, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ParseOSRelease 解析 /etc/os-release, 返回 KEY -> VALUE
+func ParseOSRelease(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	release := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		split := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(split) != 2 || strings.HasPrefix(split[0], "#") {
+			continue
+		}
+		release[split[0]] = strings.Trim(split[1], `"'`)
+	}
+	return release, scanner.Err()
+}
+
+// PackageURL 包的 purl, 如 pkg:deb/debian/curl@7.88.1-10?arch=amd64&distro=debian-12
+func (p *Package) PackageURL(distroID, distroVersion string) string {
+	namespace := distroID
+	if namespace == "" {
+		namespace = map[string]string{"apk": "alpine", "deb": "debian", "rpm": "redhat"}[p.Type]
+	}
+	purl := "pkg:" + p.Type + "/" + url.PathEscape(namespace) + "/" + url.PathEscape(p.Name) + "@" + url.PathEscape(p.Version)
+	qualifiers := make([]string, 0, 2)
+	if p.Arch != "" {
+		qualifiers = append(qualifiers, "arch="+url.QueryEscape(p.Arch))
+	}
+	if distroID != "" && distroVersion != "" {
+		qualifiers = append(qualifiers, "distro="+url.QueryEscape(distroID+"-"+distroVersion))
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	return purl
+}
@@ -0,0 +1,140 @@
+package util
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestParseDpkgStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []*Package
+	}{
+		{
+			name: "installed",
+			content: "Package: curl\nStatus: install ok installed\nArchitecture: amd64\nVersion: 7.88.1-10\n" +
+				"Description: command line tool\n multi-line description\n",
+			want: []*Package{{Type: "deb", Name: "curl", Version: "7.88.1-10", Arch: "amd64", Source: "curl"}},
+		},
+		{
+			name: "source with version",
+			content: "Package: libssl3\nStatus: install ok installed\nVersion: 3.0.11-1\nSource: openssl (3.0.11-1)\n\n" +
+				"Package: removed\nStatus: deinstall ok config-files\nVersion: 1.0\n",
+			want: []*Package{{Type: "deb", Name: "libssl3", Version: "3.0.11-1", Source: "openssl"}},
+		},
+		{
+			name:    "empty source",
+			content: "Package: base-files\nStatus: install ok installed\nVersion: 12.4\nSource: \n",
+			want:    []*Package{{Type: "deb", Name: "base-files", Version: "12.4", Source: "base-files"}},
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    []*Package{},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseDpkgStatus(writeTestFile(t, tt.content))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseDpkgStatus() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseApkInstalled(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []*Package
+	}{
+		{
+			name: "two packages",
+			content: "C:Q1abc=\nP:musl\nV:1.2.4-r2\nA:x86_64\nL:MIT\no:musl\n\n" +
+				"P:busybox\nV:1.36.1-r5\nA:x86_64\nL:GPL-2.0-only\no:busybox\n",
+			want: []*Package{
+				{Type: "apk", Name: "musl", Version: "1.2.4-r2", Arch: "x86_64", Source: "musl", License: "MIT"},
+				{Type: "apk", Name: "busybox", Version: "1.36.1-r5", Arch: "x86_64", Source: "busybox", License: "GPL-2.0-only"},
+			},
+		},
+		{
+			name:    "trailing blank lines",
+			content: "P:zlib\nV:1.3-r2\n\n\n",
+			want:    []*Package{{Type: "apk", Name: "zlib", Version: "1.3-r2"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseApkInstalled(writeTestFile(t, tt.content))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseApkInstalled() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRpmDBReadOnly(t *testing.T) {
+	// 模拟镜像层中未合并 WAL 的 rpmdb.sqlite
+	src := filepath.Join(t.TempDir(), "rpmdb.sqlite")
+	db, err := sql.Open("sqlite", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, query := range []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA wal_autocheckpoint=0",
+		"CREATE TABLE Packages (hnum INTEGER PRIMARY KEY AUTOINCREMENT, blob BLOB NOT NULL)",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := t.TempDir()
+	for _, suffix := range []string{"", "-wal"} {
+		b, err := os.ReadFile(src + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "rpmdb.sqlite"+suffix), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listDir := func() []string {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0)
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, entry.Name()+" "+info.ModTime().String()+" "+strconv.FormatInt(info.Size(), 10))
+		}
+		sort.Strings(names)
+		return names
+	}
+	before := listDir()
+	got, err := ParseRpmDB(filepath.Join(dir, "rpmdb.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("ParseRpmDB() = %+v, want no packages", got)
+	}
+	if after := listDir(); !reflect.DeepEqual(after, before) {
+		t.Errorf("ParseRpmDB() changed the database directory: %v, before %v", after, before)
+	}
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Progress 扫描进度, 定时输出到 stderr, nil 表示不输出
+type Progress struct {
+	layers int64
+	done   int64
+	files  int64
+	bytes  int64
+	stop   chan struct{}
+	exited chan struct{}
+}
+
+// NewProgress 开始定时输出扫描进度
+func NewProgress(interval time.Duration) *Progress {
+	p := &Progress{stop: make(chan struct{}), exited: make(chan struct{})}
+	go func() {
+		defer close(p.exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.stop:
+				p.print()
+				fmt.Fprintln(os.Stderr)
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *Progress) print() {
+	fmt.Fprintf(os.Stderr, "\rlayers %d/%d, files %d, read %s  ", atomic.LoadInt64(&p.done), atomic.LoadInt64(&p.layers),
+		atomic.LoadInt64(&p.files), ImageSize(atomic.LoadInt64(&p.bytes)))
+}
+
+// AddLayers 增加需要扫描的镜像层数
+func (p *Progress) AddLayers(n int) {
+	if p != nil {
+		atomic.AddInt64(&p.layers, int64(n))
+	}
+}
+
+// LayerDone 一个镜像层扫描完成
+func (p *Progress) LayerDone() {
+	if p != nil {
+		atomic.AddInt64(&p.done, 1)
+	}
+}
+
+// AddFile 已读取一个文件
+func (p *Progress) AddFile(size int64) {
+	if p != nil {
+		atomic.AddInt64(&p.files, 1)
+		atomic.AddInt64(&p.bytes, size)
+	}
+}
+
+// Stop 停止输出
+func (p *Progress) Stop() {
+	if p != nil {
+		close(p.stop)
+		<-p.exited
+	}
+}
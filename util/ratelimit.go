@@ -0,0 +1,57 @@
+package util
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter 多个协程共享的读取速率限制, nil 表示不限制
+type RateLimiter struct {
+	mu   sync.Mutex
+	rate int64     // 每秒字节数
+	next time.Time // 下一次允许读取的时间
+}
+
+// NewRateLimiter bytesPerSecond <= 0 时返回 nil, 不限制
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{rate: bytesPerSecond}
+}
+
+// Wait 预留 n 字节的读取额度, 额度不足时等待
+func (l *RateLimiter) Wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+type limitedReader struct {
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.limiter.Wait(n)
+	return n, err
+}
+
+// Reader 按速率限制读取
+func (l *RateLimiter) Reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{r: r, limiter: l}
+}
@@ -0,0 +1,133 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type SecretRule struct {
+	Name    string         // 规则名称
+	Pattern *regexp.Regexp // 匹配规则
+}
+
+type SecretFinding struct {
+	Rule   string // 规则名称
+	Line   int    // 行号, 0 表示按文件名匹配
+	Secret string // 脱敏后的内容
+}
+
+// 内置内容规则: 私钥、云厂商凭证、常见平台 token
+var SecretRules = []*SecretRule{
+	{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`)},
+	{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{Name: "gcp-service-account", Pattern: regexp.MustCompile(`"type"\s*:\s*"service_account"`)},
+	{Name: "azure-storage-key", Pattern: regexp.MustCompile(`AccountKey=[A-Za-z0-9+/=]{60,}`)},
+	{Name: "aliyun-access-key-id", Pattern: regexp.MustCompile(`\bLTAI[0-9A-Za-z]{12,20}\b`)},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{Name: "gitlab-token", Pattern: regexp.MustCompile(`\bglpat-[A-Za-z0-9\-_]{20}\b`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{Name: "stripe-secret-key", Pattern: regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{24,}\b`)},
+	{Name: "npm-token", Pattern: regexp.MustCompile(`_authToken\s*=\s*\S+`)},
+	{Name: "docker-registry-auth", Pattern: regexp.MustCompile(`"auth"\s*:\s*"[A-Za-z0-9+/=]{8,}"`)},
+	{Name: "basic-auth-url", Pattern: regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s:@]+@[^\s/]+`)},
+}
+
+// 内置文件名规则, 只匹配文件名, 按顺序取第一个匹配的规则
+var SecretFileRules = []*SecretRule{
+	{Name: "ssh-private-key", Pattern: regexp.MustCompile(`^id_(rsa|dsa|ecdsa|ed25519)$`)},
+	{Name: "dotenv", Pattern: regexp.MustCompile(`^\.env(\..+)?$`)},
+	{Name: "pkcs12", Pattern: regexp.MustCompile(`\.(p12|pfx)$`)},
+	{Name: "git-credentials", Pattern: regexp.MustCompile(`^\.git-credentials$`)},
+	{Name: "netrc", Pattern: regexp.MustCompile(`^\.netrc$`)},
+	{Name: "pypirc", Pattern: regexp.MustCompile(`^\.pypirc$`)},
+	{Name: "kube-config", Pattern: regexp.MustCompile(`^kubeconfig$`)},
+	{Name: "docker-config", Pattern: regexp.MustCompile(`^\.dockercfg$`)},
+	{Name: "shadow-file", Pattern: regexp.MustCompile(`^g?shadow$`)},
+}
+
+// RedactSecret 只保留前 4 个字符
+func RedactSecret(secret string) string {
+	if len(secret) <= 4 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
+
+// SecretFileRule 按文件名匹配, 返回规则名称
+func SecretFileRule(name string) string {
+	for _, rule := range SecretFileRules {
+		if rule.Pattern.MatchString(name) {
+			return rule.Name
+		}
+	}
+	return ""
+}
+
+// 每行最多检查的字节数, 超出部分跳过
+const secretLineSize = 1024 * 1024
+
+// ScanSecrets 按文件名及内容规则检查文件, 二进制文件只按文件名匹配
+// 超长行只检查前 1MB, 读取出错时返回已发现的结果
+func ScanSecrets(path string, limiter *RateLimiter) ([]*SecretFinding, error) {
+	findings := make([]*SecretFinding, 0)
+	// /etc/shadow 等只在有密码时才算
+	if rule := SecretFileRule(filepath.Base(path)); rule != "" && rule != "shadow-file" {
+		findings = append(findings, &SecretFinding{Rule: rule, Secret: filepath.Base(path)})
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// 缓冲区不小于检测长度, 否则 Peek 只能读到默认的 4096 字节
+	reader := bufio.NewReaderSize(limiter.Reader(f), binarySniffSize)
+	head, err := reader.Peek(binarySniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return findings, err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return findings, nil
+	}
+	shadow := SecretFileRule(filepath.Base(path)) == "shadow-file"
+	buf := make([]byte, 0, 4096)
+	for n := 1; ; {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return findings, err
+		}
+		if len(buf) < secretLineSize {
+			if len(buf)+len(chunk) > secretLineSize {
+				chunk = chunk[:secretLineSize-len(buf)]
+			}
+			buf = append(buf, chunk...)
+		}
+		if isPrefix {
+			continue
+		}
+		line := string(buf)
+		buf = buf[:0]
+		if shadow {
+			// user:$6$salt$hash:..., 没有密码时为 * 或 !
+			if split := strings.SplitN(line, ":", 3); len(split) == 3 && strings.HasPrefix(split[1], "$") {
+				findings = append(findings, &SecretFinding{Rule: "shadow-password", Line: n, Secret: split[0] + ":" + RedactSecret(split[1])})
+			}
+		} else {
+			for _, rule := range SecretRules {
+				if match := rule.Pattern.FindString(line); match != "" {
+					findings = append(findings, &SecretFinding{Rule: rule.Name, Line: n, Secret: RedactSecret(match)})
+				}
+			}
+		}
+		n++
+	}
+	return findings, nil
+}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ref: github.com/vbatts/tar-split/tar/storage
+const (
+	tarSplitFileType    = 1 // 文件内容, 从 diff 目录读取
+	tarSplitSegmentType = 2 // tar 头部等原始数据
+)
+
+type tarSplitEntry struct {
+	Type    int    `json:"type"`
+	Name    string `json:"name,omitempty"`
+	NameRaw []byte `json:"name_raw,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Payload []byte `json:"payload"`
+}
+
+// TarSplitDigest 根据 tar-split 元数据与 diff 目录重新组装层 tar 流, 返回 sha256
+func TarSplitDigest(tarSplitPath, diffPath string) (string, error) {
+	f, err := os.Open(tarSplitPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	defer gz.Close()
+	hash := sha256.New()
+	decoder := json.NewDecoder(bufio.NewReader(gz))
+	for {
+		entry := tarSplitEntry{}
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		switch entry.Type {
+		case tarSplitSegmentType:
+			hash.Write(entry.Payload)
+		case tarSplitFileType:
+			if entry.Size == 0 {
+				continue
+			}
+			name := entry.Name
+			if len(entry.NameRaw) > 0 {
+				name = string(entry.NameRaw)
+			}
+			// 拒绝逃出 diff 目录的条目, 防止被篡改的 tar-split 读取宿主机文件
+			clean := filepath.Clean(name)
+			if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+				return "", fmt.Errorf("tar-split entry %q escapes layer", name)
+			}
+			if err := copyFileN(hash, filepath.Join(diffPath, clean), entry.Size); err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("unknown tar-split entry type %d", entry.Type)
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func copyFileN(w io.Writer, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return errors.New(path + ": size mismatch")
+	}
+	_, err = io.CopyN(w, f, size)
+	return err
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTarSplit 写入 gzip 压缩的 tar-split 元数据
+func writeTarSplit(t *testing.T, entries []*tarSplitEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tar-split.json.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	encoder := json.NewEncoder(gz)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTarSplitDigest(t *testing.T) {
+	diffPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(diffPath, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(diffPath, "etc", "hostname"), []byte("docker\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	header := []byte(strings.Repeat("h", 512))
+	padding := []byte(strings.Repeat("\x00", 505+1024))
+	want := fmt.Sprintf("%x", sha256.Sum256(append(append(append([]byte{}, header...), "docker\n"...), padding...)))
+	tests := []struct {
+		name    string
+		entries []*tarSplitEntry
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "reassemble",
+			entries: []*tarSplitEntry{
+				{Type: tarSplitSegmentType, Payload: header},
+				{Type: tarSplitFileType, Name: "etc/hostname", Size: 7},
+				{Type: tarSplitSegmentType, Payload: padding},
+			},
+			want: want,
+		},
+		{
+			name: "raw name",
+			entries: []*tarSplitEntry{
+				{Type: tarSplitSegmentType, Payload: header},
+				{Type: tarSplitFileType, NameRaw: []byte("./etc/hostname"), Size: 7},
+				{Type: tarSplitSegmentType, Payload: padding},
+			},
+			want: want,
+		},
+		{
+			name:    "size mismatch",
+			entries: []*tarSplitEntry{{Type: tarSplitFileType, Name: "etc/hostname", Size: 8}},
+			wantErr: true,
+		},
+		{
+			name:    "parent directory",
+			entries: []*tarSplitEntry{{Type: tarSplitFileType, Name: "etc/../../hostname", Size: 7}},
+			wantErr: true,
+		},
+		{
+			name:    "absolute path",
+			entries: []*tarSplitEntry{{Type: tarSplitFileType, Name: "/etc/hostname", Size: 7}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			entries: []*tarSplitEntry{{Type: 3}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := TarSplitDigest(writeTarSplit(t, tt.entries), diffPath)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: TarSplitDigest() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: TarSplitDigest() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
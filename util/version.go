@@ -0,0 +1,312 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// CompareVersion 按包类型比较版本, a < b 返回 -1, 相同返回 0, a > b 返回 1
+func CompareVersion(pkgType, a, b string) int {
+	switch pkgType {
+	case "deb":
+		return CompareDebVersion(a, b)
+	case "apk":
+		return CompareApkVersion(a, b)
+	}
+	return CompareRpmVersion(a, b)
+}
+
+// splitEpoch 拆分 epoch:version, 没有 epoch 时为 0
+func splitEpoch(version string) (int, string) {
+	if n := strings.IndexByte(version, ':'); n > 0 {
+		if epoch, err := strconv.Atoi(version[:n]); err == nil {
+			return epoch, version[n+1:]
+		}
+	}
+	return 0, version
+}
+
+// CompareDebVersion 与 dpkg --compare-versions 规则相同: [epoch:]upstream[-revision]
+func CompareDebVersion(a, b string) int {
+	epochA, a := splitEpoch(a)
+	epochB, b := splitEpoch(b)
+	if epochA != epochB {
+		return sign(epochA - epochB)
+	}
+	upstreamA, revisionA := a, ""
+	if n := strings.LastIndexByte(a, '-'); n >= 0 {
+		upstreamA, revisionA = a[:n], a[n+1:]
+	}
+	upstreamB, revisionB := b, ""
+	if n := strings.LastIndexByte(b, '-'); n >= 0 {
+		upstreamB, revisionB = b[:n], b[n+1:]
+	}
+	if c := debVerrevcmp(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+	return debVerrevcmp(revisionA, revisionB)
+}
+
+// debOrder ~ 排在最前(比结束还小), 字母在其他符号之前
+func debOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+func debVerrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := debOrder(a, i), debOrder(b, j)
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// CompareRpmVersion 与 rpmvercmp 规则相同: [epoch:]version[-release]
+func CompareRpmVersion(a, b string) int {
+	epochA, a := splitEpoch(a)
+	epochB, b := splitEpoch(b)
+	if epochA != epochB {
+		return sign(epochA - epochB)
+	}
+	versionA, releaseA := a, ""
+	if n := strings.LastIndexByte(a, '-'); n >= 0 {
+		versionA, releaseA = a[:n], a[n+1:]
+	}
+	versionB, releaseB := b, ""
+	if n := strings.LastIndexByte(b, '-'); n >= 0 {
+		versionB, releaseB = b[:n], b[n+1:]
+	}
+	if c := rpmvercmp(versionA, versionB); c != 0 {
+		return c
+	}
+	// 只有一方带 release 时不比较 release
+	if releaseA == "" || releaseB == "" {
+		return 0
+	}
+	return rpmvercmp(releaseA, releaseB)
+}
+
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isDigit(a[i]) && !isAlpha(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isDigit(b[j]) && !isAlpha(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+		// ~ 表示预发布版本, 比任何内容都小
+		tildeA, tildeB := i < len(a) && a[i] == '~', j < len(b) && b[j] == '~'
+		if tildeA || tildeB {
+			if !tildeA {
+				return 1
+			}
+			if !tildeB {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		// ^ 表示发布后的快照版本, 比结束大, 比其他内容小
+		caretA, caretB := i < len(a) && a[i] == '^', j < len(b) && b[j] == '^'
+		if caretA || caretB {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if !caretA {
+				return 1
+			}
+			if !caretB {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+		si, sj := i, j
+		isNum := isDigit(a[i])
+		if isNum {
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+		} else {
+			for i < len(a) && isAlpha(a[i]) {
+				i++
+			}
+			for j < len(b) && isAlpha(b[j]) {
+				j++
+			}
+		}
+		segA, segB := a[si:i], b[sj:j]
+		// 数字段比字母段新
+		if segB == "" {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		if isNum {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				return sign(len(segA) - len(segB))
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+	if i >= len(a) && j >= len(b) {
+		return 0
+	}
+	if i >= len(a) {
+		return -1
+	}
+	return 1
+}
+
+// apk 版本后缀顺序, 未知后缀按 0 处理
+var apkSuffixes = map[string]int{
+	"alpha": -4, "beta": -3, "pre": -2, "rc": -1,
+	"cvs": 1, "svn": 2, "git": 3, "hg": 4, "p": 5,
+}
+
+type apkVersion struct {
+	numbers  []int
+	letter   byte
+	suffixes [][2]int
+	release  int
+}
+
+// parseApkVersion 解析 1.2.3a_rc1_p2-r0
+func parseApkVersion(version string) apkVersion {
+	v := apkVersion{}
+	if n := strings.LastIndex(version, "-r"); n >= 0 {
+		v.release, _ = strconv.Atoi(version[n+2:])
+		version = version[:n]
+	}
+	split := strings.Split(version, "_")
+	base := split[0]
+	if len(base) > 0 && isAlpha(base[len(base)-1]) {
+		v.letter = base[len(base)-1]
+		base = base[:len(base)-1]
+	}
+	for _, number := range strings.Split(base, ".") {
+		n, _ := strconv.Atoi(number)
+		v.numbers = append(v.numbers, n)
+	}
+	for _, suffix := range split[1:] {
+		name := strings.TrimRight(suffix, "0123456789")
+		n, _ := strconv.Atoi(suffix[len(name):])
+		v.suffixes = append(v.suffixes, [2]int{apkSuffixes[name], n})
+	}
+	return v
+}
+
+// CompareApkVersion 比较 apk 版本: 数字段、字母、后缀(_alpha < _beta < _pre < _rc < 无 < _p)、-r 发布号
+func CompareApkVersion(a, b string) int {
+	va, vb := parseApkVersion(a), parseApkVersion(b)
+	for n := 0; n < len(va.numbers) || n < len(vb.numbers); n++ {
+		x, y := -1, -1
+		if n < len(va.numbers) {
+			x = va.numbers[n]
+		}
+		if n < len(vb.numbers) {
+			y = vb.numbers[n]
+		}
+		if x != y {
+			return sign(x - y)
+		}
+	}
+	if va.letter != vb.letter {
+		return sign(int(va.letter) - int(vb.letter))
+	}
+	for n := 0; n < len(va.suffixes) || n < len(vb.suffixes); n++ {
+		var x, y [2]int
+		if n < len(va.suffixes) {
+			x = va.suffixes[n]
+		}
+		if n < len(vb.suffixes) {
+			y = vb.suffixes[n]
+		}
+		if x[0] != y[0] {
+			return sign(x[0] - y[0])
+		}
+		if x[1] != y[1] {
+			return sign(x[1] - y[1])
+		}
+	}
+	return sign(va.release - vb.release)
+}
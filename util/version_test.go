@@ -0,0 +1,64 @@
+package util
+
+import "testing"
+
+func TestCompareDebVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1:1.0", "2.0", 1},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+deb12u1", -1},
+		{"7.88.1-10+deb12u5", "7.88.1-10+deb12u4", 1},
+		{"2.36-9+deb12u3", "2.36-9+deb12u10", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareDebVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareDebVersion(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareRpmVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0-1.el9", "1.0-1.el9", 0},
+		{"1.0-1.el9", "1.0-2.el9", -1},
+		{"1.10-1", "1.9-1", 1},
+		{"1:1.0-1", "2.0-1", 1},
+		{"1.0~rc1-1", "1.0-1", -1},
+		{"1.0a-1", "1.0-1", 1},
+		{"2.34-60.el9", "2.34-100.el9", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareRpmVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareRpmVersion(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareApkVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3-r0", "1.2.3-r0", 0},
+		{"1.2.3-r0", "1.2.3-r1", -1},
+		{"1.2.10-r0", "1.2.9-r0", 1},
+		{"1.2.3_rc1-r0", "1.2.3-r0", -1},
+		{"1.2.3_p1-r0", "1.2.3-r0", 1},
+		{"3.1.4-r5", "3.1.4-r10", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareApkVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareApkVersion(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}